@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// rotateKey swaps in a client of the configured provider using a new API key
+// without a restart. The key is checked before the swap, and the old client
+// stays open until requests already using it have timed out at the latest.
+func (app *Application) rotateKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		APIKey string `json:"apiKey" validate:"required"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if errs := validate(&req); len(errs) > 0 {
+		writeFieldErrors(w, errs)
+		return
+	}
+
+	cfg := *app.Config
+	if cfg.LLMProvider == "openai" {
+		cfg.OpenAIKey = req.APIKey
+	} else {
+		cfg.GeminiKey = req.APIKey
+	}
+	client, err := newLLMProvider(&cfg, app.TokenBudget)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Gemini error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := client.Ping(r.Context()); err != nil {
+		client.Close()
+		http.Error(w, fmt.Sprintf("The new key was rejected: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	old := app.llm.Swap(&client)
+	time.AfterFunc(app.drainTimeout(), (*old).Close)
+	log.Printf("%s API key rotated", cfg.LLMProvider)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "API key rotated",
+	})
+}
+
+// drainTimeout is the longest any request can keep using a provider client.
+func (app *Application) drainTimeout() time.Duration {
+	return max(app.Config.RequestTimeout, app.Config.GenerateTimeout, app.Config.QueryTimeout)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"genai/internal/gemini"
+)
+
+// rowsPerBatch is the most rows per table asked of the model in one call.
+// Larger rowsPerTable requests are split into several calls, since a single
+// response with thousands of INSERTs would run into the output token limit.
+const rowsPerBatch = 50
+
+// generateBatches asks the model for rowsPerTable rows in every table of
+// schema, rowsPerBatch at a time, and joins the batches' SQL and warnings.
+// Each batch's SQL is terminated, so its last statement can't run into the
+// first one of the next batch.
+// Each batch is told how many rows came before it, and key starts in opts
+// are moved past them, so its keys follow on from the earlier batches'.
+func (app *Application) generateBatches(ctx context.Context, schema string, opts gemini.GenerateOptions, rowsPerTable int) (*gemini.Generation, error) {
+	keyStarts := opts.KeyStarts
+	batches := (rowsPerTable + rowsPerBatch - 1) / rowsPerBatch
+
+	var sqlParts []string
+	result := &gemini.Generation{}
+	for i := 0; i < batches; i++ {
+		done := i * rowsPerBatch
+		opts.RowsPerTable = min(rowsPerBatch, rowsPerTable-done)
+		opts.PriorRows = done
+		if len(keyStarts) > 0 {
+			opts.KeyStarts = make(map[string]int64, len(keyStarts))
+			for col, start := range keyStarts {
+				opts.KeyStarts[col] = start + int64(done)
+			}
+		}
+
+		callCtx, cancel := app.llmContext(ctx)
+		gen, err := app.LLM().GenerateDataSQL(callCtx, schema, opts)
+		err = app.llmError(callCtx, err)
+		cancel()
+		if err != nil {
+			if batches > 1 {
+				return nil, fmt.Errorf("batch %d of %d: %w", i+1, batches, err)
+			}
+			return nil, err
+		}
+		if part := terminate(gen.SQL); part != "" {
+			sqlParts = append(sqlParts, part)
+		}
+		for _, warning := range gen.Warnings {
+			if batches > 1 {
+				warning = fmt.Sprintf("batch %d of %d: %s", i+1, batches, warning)
+			}
+			result.Warnings = append(result.Warnings, warning)
+		}
+	}
+	result.SQL = strings.Join(sqlParts, "\n")
+	return result, nil
+}
+
+// terminate trims sql and ends it with a semicolon if it doesn't have one.
+// The semicolon goes on a line of its own after a trailing -- comment.
+func terminate(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if sql == "" || strings.HasSuffix(sql, ";") {
+		return sql
+	}
+	if lastLine := sql[strings.LastIndexByte(sql, '\n')+1:]; strings.Contains(lastLine, "--") {
+		return sql + "\n;"
+	}
+	return sql + ";"
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"genai/internal/config"
+	"genai/internal/database"
+	"genai/internal/gemini"
+)
+
+func TestGenerateBatchesTerminatesParts(t *testing.T) {
+	var llm LLMProvider = &stubProvider{sql: "INSERT INTO customers (id, name) VALUES (1, 'Ann')"}
+	app := &Application{Config: &config.Config{LLMTimeout: time.Minute}}
+	app.llm.Store(&llm)
+
+	gen, err := app.generateBatches(context.Background(), "", gemini.GenerateOptions{}, 2*rowsPerBatch+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statements := database.SplitStatements(gen.SQL); len(statements) != 3 {
+		t.Errorf("the batches joined into %d statements, want 3:\n%s", len(statements), gen.SQL)
+	}
+}
+
+func TestTerminate(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"INSERT INTO t VALUES (1)", "INSERT INTO t VALUES (1);"},
+		{"INSERT INTO t VALUES (1);\n", "INSERT INTO t VALUES (1);"},
+		{"INSERT INTO t VALUES (1) -- last row", "INSERT INTO t VALUES (1) -- last row\n;"},
+		{"  \n", ""},
+	}
+	for _, tt := range tests {
+		if got := terminate(tt.sql); got != tt.want {
+			t.Errorf("terminate(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
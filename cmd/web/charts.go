@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// chartTypes are the chart types the UI knows how to render.
+var chartTypes = map[string]bool{"bar": true, "pie": true, "line": true, "doughnut": true}
+
+type columnKind int
+
+const (
+	kindUnknown columnKind = iota // only NULLs seen
+	kindNumeric
+	kindText
+	kindDate
+)
+
+// chartAxes picks the columns to plot: the first numeric column as the value
+// and the first text or date column as the label, falling back to the first
+// other column. It fails when there is no numeric column.
+func chartAxes(cols []string, result []map[string]interface{}) (string, string, error) {
+	kinds := make(map[string]columnKind, len(cols))
+	for _, col := range cols {
+		for _, row := range result {
+			if v := row[col]; v != nil {
+				kinds[col] = kindOf(v)
+				break
+			}
+		}
+	}
+
+	valueColumn := ""
+	for _, col := range cols {
+		if kinds[col] == kindNumeric {
+			valueColumn = col
+			break
+		}
+	}
+	if valueColumn == "" {
+		return "", "", fmt.Errorf("a chart can't be built: the result has no numeric column")
+	}
+
+	labelColumn := ""
+	for _, col := range cols {
+		if kinds[col] == kindText || kinds[col] == kindDate {
+			labelColumn = col
+			break
+		}
+	}
+	if labelColumn == "" {
+		for _, col := range cols {
+			if col != valueColumn {
+				labelColumn = col
+				break
+			}
+		}
+	}
+	if labelColumn == "" {
+		return "", "", fmt.Errorf("a chart can't be built: the result needs a label column besides %s", valueColumn)
+	}
+
+	return labelColumn, valueColumn, nil
+}
+
+func kindOf(v interface{}) columnKind {
+	switch x := v.(type) {
+	case int64, float64:
+		return kindNumeric
+	case time.Time:
+		return kindDate
+	case string:
+		// lib/pq returns numeric columns as text
+		if _, err := strconv.ParseFloat(x, 64); err == nil {
+			return kindNumeric
+		}
+	}
+	return kindText
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"genai/internal/database"
+	"genai/internal/gemini"
+)
+
+// debugPrompt returns the system instruction and prompt that a generation or
+// query request would send to the model, without calling the model.
+func (app *Application) debugPrompt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Kind               string                         `json:"kind"` // "generate" or "query"
+		Prompt             string                         `json:"prompt"`
+		Preset             string                         `json:"preset"`
+		ReferentialDensity map[string]float64             `json:"referentialDensity"`
+		NumericRanges      map[string]gemini.NumericRange `json:"numericRanges"`
+		TimeRange          *gemini.TimeRange              `json:"timeRange"`
+		SafeText           bool                           `json:"safeText"`
+		SafeContent        bool                           `json:"safeContent"`
+		NullRates          map[string]float64             `json:"nullRates"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tables, err := database.GetStructuredSchema(r.Context())
+	if err != nil {
+		http.Error(w, "Error fetching schema", http.StatusInternalServerError)
+		return
+	}
+	fks, err := database.GetForeignKeys(r.Context())
+	if err != nil {
+		http.Error(w, "Error fetching foreign keys", http.StatusInternalServerError)
+		return
+	}
+
+	// render builds the prompt for a given schema text, so the compact and
+	// verbose schema formats can be compared
+	var render func(schema string) (string, string)
+	switch req.Kind {
+	case "generate":
+		opts := gemini.GenerateOptions{
+			Dialect:            app.Config.DatabaseDialect,
+			Preset:             req.Preset,
+			ReferentialDensity: req.ReferentialDensity,
+			NumericRanges:      req.NumericRanges,
+			TimeRange:          req.TimeRange,
+			SafeText:           req.SafeText,
+			SafeContent:        req.SafeContent,
+			NullRates:          req.NullRates,
+		}
+		if err := opts.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tables = database.InsertableColumns(database.OrderByDependencies(tables, fks))
+		render = func(schema string) (string, string) {
+			return app.LLM().GenerationPrompt(schema, opts)
+		}
+	case "query":
+		if req.Prompt == "" {
+			http.Error(w, "prompt is required for query prompts", http.StatusBadRequest)
+			return
+		}
+		render = func(schema string) (string, string) {
+			return app.LLM().QueryPrompt(schema, req.Prompt, time.Now())
+		}
+	default:
+		http.Error(w, `kind must be "generate" or "query"`, http.StatusBadRequest)
+		return
+	}
+
+	system, prompt := render(database.FormatSchema(tables, fks))
+	response := map[string]interface{}{
+		"systemInstruction": system,
+		"prompt":            prompt,
+	}
+
+	// ?countTokens=true reports the prompt size next to what the verbose
+	// schema format would cost. This calls the Gemini API.
+	if r.URL.Query().Get("countTokens") == "true" {
+		tokens, err := app.LLM().CountTokens(r.Context(), system+"\n"+prompt)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Gemini error: %v", err), http.StatusInternalServerError)
+			return
+		}
+		verboseSystem, verbosePrompt := render(database.FormatSchemaVerbose(tables, fks))
+		verboseTokens, err := app.LLM().CountTokens(r.Context(), verboseSystem+"\n"+verbosePrompt)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Gemini error: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response["tokens"] = tokens
+		response["verboseTokens"] = verboseTokens
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"genai/internal/database"
+)
+
+// dictTable is a table as listed in the data dictionary.
+type dictTable struct {
+	Name        string
+	Description string
+	Columns     []dictColumn
+}
+
+type dictColumn struct {
+	Name        string
+	Type        string
+	Nullable    bool
+	Constraints []string // e.g. PRIMARY KEY, UNIQUE, REFERENCES users(id)
+}
+
+// maxCachedDescriptions bounds the table description cache; it is reset
+// once full.
+const maxCachedDescriptions = 50
+
+// descriptionCache holds the model's table descriptions keyed by the hash of
+// the schema they were written for, so the dictionary of an unchanged schema
+// doesn't cost another call.
+type descriptionCache struct {
+	mu      sync.Mutex
+	entries map[string]map[string]string
+}
+
+func newDescriptionCache() *descriptionCache {
+	return &descriptionCache{entries: make(map[string]map[string]string)}
+}
+
+func (c *descriptionCache) get(key string) (map[string]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	descriptions, ok := c.entries[key]
+	return descriptions, ok
+}
+
+func (c *descriptionCache) put(key string, descriptions map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCachedDescriptions {
+		c.entries = make(map[string]map[string]string)
+	}
+	c.entries[key] = descriptions
+}
+
+// dataDictionary documents every table and column of the schema, as
+// Markdown or, with ?format=html, an HTML page. With ?describe=true the
+// model is asked what each table is for.
+func (app *Application) dataDictionary(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		format = "md"
+	case "md", "html":
+	default:
+		http.Error(w, "format must be md or html", http.StatusBadRequest)
+		return
+	}
+
+	tables, err := buildDictionary(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reading schema: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("describe") == "true" && len(tables) > 0 {
+		descriptions, err := app.describeTables(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Gemini error: %v", err), geminiErrorStatus(err))
+			return
+		}
+		for i := range tables {
+			tables[i].Description = descriptions[tables[i].Name]
+		}
+	}
+
+	if format == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := dictionaryHTML.Execute(w, tables); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Write([]byte(dictionaryMarkdown(tables)))
+}
+
+// buildDictionary collects the tables and columns of the schema with each
+// column's key, unique and foreign key constraints.
+func buildDictionary(ctx context.Context) ([]dictTable, error) {
+	tables, err := database.GetStructuredSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	uniques, err := database.GetUniqueColumns(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fks, err := database.GetForeignKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dict := make([]dictTable, len(tables))
+	for i, table := range tables {
+		pk, err := database.GetPrimaryKey(ctx, table.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		dict[i] = dictTable{Name: table.Name}
+		for _, col := range table.Columns {
+			c := dictColumn{Name: col.Name, Type: col.TypeName(), Nullable: col.Nullable}
+			switch {
+			case pk != nil && pk.Column == col.Name:
+				c.Constraints = append(c.Constraints, "PRIMARY KEY")
+			case slices.Contains(uniques[table.Name], col.Name):
+				c.Constraints = append(c.Constraints, "UNIQUE")
+			}
+			for _, fk := range fks {
+				if fk.Table == table.Name && fk.Column == col.Name {
+					c.Constraints = append(c.Constraints, fmt.Sprintf("REFERENCES %s(%s)", fk.RefTable, fk.RefColumn))
+				}
+			}
+			if col.Generated {
+				c.Constraints = append(c.Constraints, "GENERATED")
+			}
+			dict[i].Columns = append(dict[i].Columns, c)
+		}
+	}
+	return dict, nil
+}
+
+// describeTables returns the model's table descriptions for the current
+// schema, from the cache when the schema hasn't changed.
+func (app *Application) describeTables(ctx context.Context) (map[string]string, error) {
+	schema, err := database.GetSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(schema))
+	key := hex.EncodeToString(sum[:])
+	if descriptions, ok := app.Descriptions.get(key); ok {
+		return descriptions, nil
+	}
+
+	callCtx, cancel := app.llmContext(ctx)
+	descriptions, err := app.LLM().DescribeTables(callCtx, schema)
+	err = app.llmError(callCtx, err)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	app.Descriptions.put(key, descriptions)
+	return descriptions, nil
+}
+
+// dictionaryMarkdown renders the data dictionary as Markdown, one section
+// with a column table per table.
+func dictionaryMarkdown(tables []dictTable) string {
+	// Pipes and line breaks would break the table layout
+	cell := strings.NewReplacer("|", `\|`, "\n", " ").Replace
+
+	var sb strings.Builder
+	sb.WriteString("# Data Dictionary\n")
+	for _, table := range tables {
+		sb.WriteString(fmt.Sprintf("\n## %s\n\n", table.Name))
+		if table.Description != "" {
+			sb.WriteString(table.Description + "\n\n")
+		}
+		sb.WriteString("| Column | Type | Nullable | Constraints |\n")
+		sb.WriteString("| --- | --- | --- | --- |\n")
+		for _, col := range table.Columns {
+			nullable := "NO"
+			if col.Nullable {
+				nullable = "YES"
+			}
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n", cell(col.Name), cell(col.Type), nullable, cell(strings.Join(col.Constraints, ", "))))
+		}
+	}
+	return sb.String()
+}
+
+var dictionaryHTML = template.Must(template.New("dictionary").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Data Dictionary</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+</style>
+</head>
+<body>
+<h1>Data Dictionary</h1>
+{{range .}}
+<h2 id="{{.Name}}">{{.Name}}</h2>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+<table>
+<tr><th>Column</th><th>Type</th><th>Nullable</th><th>Constraints</th></tr>
+{{range .Columns}}<tr><td>{{.Name}}</td><td>{{.Type}}</td><td>{{if .Nullable}}YES{{else}}NO{{end}}</td><td>{{range $i, $c := .Constraints}}{{if $i}}, {{end}}{{$c}}{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
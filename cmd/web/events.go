@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// eventStream writes server-sent events, flushing each one to the client as
+// it is sent.
+type eventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	started bool // headers written; errors must now be sent as events
+}
+
+// newEventStream returns a stream over w, or false if w can't flush. No
+// headers are written until the first event, so until then the handler can
+// still fail with a plain http.Error.
+func newEventStream(w http.ResponseWriter) (*eventStream, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, false
+	}
+	return &eventStream{w: w, flusher: flusher}, true
+}
+
+// send writes one event with data encoded as JSON.
+func (s *eventStream) send(event string, data interface{}) {
+	if !s.started {
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.Header().Set("Connection", "keep-alive")
+		s.started = true
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		payload, _ = json.Marshal(map[string]string{"error": err.Error()})
+		event = "error"
+	}
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload)
+	s.flusher.Flush()
+}
+
+// wantsEventStream reports whether the client asked for server-sent events.
+func wantsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
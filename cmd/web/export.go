@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"genai/internal/database"
+)
+
+// export streams the result of a user-written SELECT as CSV or JSON, for
+// filtered or joined result sets that the whole-table downloads can't give.
+// The query passes the same checks as /run-sql and also runs in a read-only
+// transaction, so nothing it does can write.
+func (app *Application) export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		SQL    string `json:"sql" validate:"required"`
+		Format string `json:"format" validate:"oneof=csv json"` // defaults to csv
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if errs := validate(&req); len(errs) > 0 {
+		writeFieldErrors(w, errs)
+		return
+	}
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+
+	if reason := unsafeReason(req.SQL); reason != "" {
+		http.Error(w, fmt.Sprintf("Unsafe query. Operation blocked: %s.", reason), http.StatusForbidden)
+		return
+	}
+	if err := app.checkTableAccess(req.SQL); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	maxCell, err := parseMaxCell(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, done, err := database.QueryReadOnly(r.Context(), req.SQL)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Query execution error: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer done()
+
+	cols, _ := rows.Columns()
+	setAttachment(w, exportFilename(r, "export", req.Format))
+
+	if req.Format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		arrays := arrayColumns(rows)
+		w.Write([]byte("["))
+		first := true
+		for rows.Next() {
+			m, err := scanRow(rows, cols, arrays, false)
+			if err != nil {
+				abortStream("export", err)
+			}
+			record, err := json.Marshal(m)
+			if err != nil {
+				abortStream("export", err)
+			}
+			if !first {
+				w.Write([]byte(","))
+			}
+			first = false
+			w.Write(record)
+		}
+		// A closing ] would make a partial result look complete
+		if err := rows.Err(); err != nil {
+			abortStream("export", err)
+		}
+		w.Write([]byte("]\n"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	csvWriter := csv.NewWriter(w)
+	defer csvWriter.Flush()
+
+	csvWriter.Write(cols)
+	for rows.Next() {
+		columns := make([]interface{}, len(cols))
+		columnPointers := make([]interface{}, len(cols))
+		for i := range columns {
+			columnPointers[i] = &columns[i]
+		}
+
+		if err := rows.Scan(columnPointers...); err != nil {
+			abortStream("export", err)
+		}
+
+		record := make([]string, len(cols))
+		for i, val := range columns {
+			record[i] = truncateCell(formatValue(val), maxCell)
+		}
+		csvWriter.Write(record)
+	}
+	if err := rows.Err(); err != nil {
+		abortStream("export", err)
+	}
+}
+
+// abortStream ends a streamed download that failed after its headers were
+// sent. It drops the connection instead of finishing the response, so the
+// client sees a broken transfer rather than a file that silently lacks rows.
+func abortStream(what string, err error) {
+	log.Printf("%s: %v", what, err)
+	panic(http.ErrAbortHandler)
+}
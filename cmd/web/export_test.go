@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestExportAbortsOnRowError checks that an error partway through the rows
+// drops the connection instead of ending the file as if it were complete.
+func TestExportAbortsOnRowError(t *testing.T) {
+	app := newTestApp(t, &stubProvider{}, testSchema)
+	// abs() of the smallest integer overflows on the second row only
+	const failing = `SELECT CASE WHEN id = 2 THEN abs(-9223372036854775807 - 1) ELSE id END AS n FROM customers ORDER BY id`
+
+	for _, format := range []string{"csv", "json"} {
+		t.Run(format, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			body := `{"sql": "` + failing + `", "format": "` + format + `"}`
+			req := httptest.NewRequest(http.MethodPost, "/export", strings.NewReader(body))
+
+			defer func() {
+				if r := recover(); r != http.ErrAbortHandler {
+					t.Errorf("recovered %v, want http.ErrAbortHandler", r)
+				}
+				if strings.HasSuffix(strings.TrimSpace(rec.Body.String()), "]") {
+					t.Errorf("aborted JSON export was closed: %q", rec.Body)
+				}
+			}()
+			app.export(rec, req)
+			t.Errorf("export finished normally with status %d: %q", rec.Code, rec.Body)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"genai/internal/database"
+)
+
+// execGenerated runs a generated statement in tx. When it fails because the
+// model invented a column and FIX_UNDEFINED_COLUMNS is on, Gemini is asked to
+// correct it against schema and the correction, passed through prepare, is
+// run once in its place. It returns the statement that ran, or the original
+// one when it failed, how many rows it inserted and whether it was corrected.
+func (app *Application) execGenerated(ctx context.Context, tx *sql.Tx, schema, stmt string, prepare func(string) (string, error)) (string, int64, bool, error) {
+	if !app.Config.FixUndefinedColumns {
+		res, err := tx.ExecContext(ctx, stmt)
+		return stmt, rowsAffected(res, err), false, err
+	}
+
+	// A failed statement aborts the transaction, so a savepoint is needed
+	// for the correction to run after it
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT generated_statement"); err != nil {
+		return stmt, 0, false, err
+	}
+	res, err := tx.ExecContext(ctx, stmt)
+	if err == nil || !database.IsUndefinedColumn(err) {
+		return stmt, rowsAffected(res, err), false, err
+	}
+	if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT generated_statement"); rbErr != nil {
+		return stmt, 0, false, err
+	}
+
+	// Whatever goes wrong while correcting, the original error is the one
+	// worth reporting, unless the model timed out, which is answered with
+	// 504 like any other model call
+	callCtx, cancel := app.llmContext(ctx)
+	fixed, fixErr := app.LLM().FixSQL(callCtx, schema, stmt, err.Error())
+	fixErr = app.llmError(callCtx, fixErr)
+	cancel()
+	if fixErr == nil {
+		fixed, fixErr = prepare(fixed)
+	}
+	if fixErr != nil {
+		log.Printf("correcting generated SQL: %v", fixErr)
+		if errors.Is(fixErr, context.DeadlineExceeded) {
+			return stmt, 0, false, fmt.Errorf("%v; correcting it failed: %w", err, fixErr)
+		}
+		return stmt, 0, false, err
+	}
+	res, fixErr = tx.ExecContext(ctx, fixed)
+	if fixErr != nil {
+		log.Printf("corrected generated SQL failed: %v", fixErr)
+		return stmt, 0, false, err
+	}
+	return fixed, rowsAffected(res, nil), true, nil
+}
+
+// rowsAffected returns the rows affected by a successful Exec, or 0.
+func rowsAffected(res sql.Result, err error) int64 {
+	if err != nil {
+		return 0
+	}
+	n, _ := res.RowsAffected()
+	return n
+}
@@ -0,0 +1,13 @@
+package main
+
+import "genai/internal/generators"
+
+// registerGenerators is where deployments plug in generators for their own
+// column formats, for example:
+//
+//	reg.Register(`^account_number$`, generators.Luhn(12))
+//
+// Values from a matching generator replace whatever the model produced.
+func registerGenerators(reg *generators.Registry) error {
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"genai/internal/database"
+)
+
+// Page size limits for /query-history.
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// queryHistory returns the most recent successful natural language queries,
+// newest first, ?limit of them. Any of them can be run again by posting its
+// id to /query as historyId.
+func (app *Application) queryHistory(w http.ResponseWriter, r *http.Request) {
+	limit := defaultHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxHistoryLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxHistoryLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	entries, err := database.QueryHistory(r.Context(), limit)
+	if errors.Is(err, database.ErrHistoryDisabled) {
+		http.Error(w, "Query history is disabled", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries": entries,
+	})
+}
+
+// replayQuery returns the SQL recorded for a history entry, without asking
+// the model again. It is checked like freshly generated SQL, since the
+// safety rules or table allow-list may have changed since it was recorded.
+// On failure it also returns the HTTP status to reply with.
+func (app *Application) replayQuery(ctx context.Context, id int64) (*nlQuery, int, error) {
+	entry, err := database.GetHistoryEntry(ctx, id)
+	if errors.Is(err, database.ErrHistoryDisabled) {
+		return nil, http.StatusNotFound, errors.New("Query history is disabled")
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Database error: %v", err)
+	}
+	if entry == nil {
+		return nil, http.StatusNotFound, fmt.Errorf("No query history entry %d", id)
+	}
+
+	if reason := unsafeReason(entry.SQL); reason != "" {
+		return nil, http.StatusForbidden, fmt.Errorf("Unsafe query. Operation blocked: %s.", reason)
+	}
+	if err := app.checkTableAccess(entry.SQL); err != nil {
+		return nil, http.StatusForbidden, err
+	}
+	return &nlQuery{SQL: entry.SQL, IsChart: entry.IsChart, ChartType: entry.ChartType}, 0, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"genai/internal/database"
+)
+
+func TestReplayRecordsOneHistoryRow(t *testing.T) {
+	llm := &stubProvider{sql: "SELECT name FROM customers ORDER BY id"}
+	app := newTestApp(t, llm, testSchema)
+	if err := database.OpenHistory(filepath.Join(t.TempDir(), "history.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.CloseHistory)
+
+	post := func(body string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		app.query(rec, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST /query %s: status %d: %s", body, rec.Code, rec.Body)
+		}
+	}
+
+	post(`{"prompt": "customer names"}`)
+	entries, err := database.QueryHistory(context.Background(), maxHistoryLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("a query recorded %d history rows, want 1", len(entries))
+	}
+
+	post(`{"historyId": ` + strconv.FormatInt(entries[0].ID, 10) + `}`)
+	if entries, err = database.QueryHistory(context.Background(), maxHistoryLimit); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("after a replay there are %d history rows, want 1", len(entries))
+	}
+	if llm.calls != 1 {
+		t.Errorf("the model was called %d times, want once", llm.calls)
+	}
+
+	// The history is kept apart from the queried database
+	if database.IsValidTableName(context.Background(), "query_history") {
+		t.Error("query_history was created in the queried database")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"genai/internal/database"
+	"genai/internal/gemini"
+	"genai/internal/generators"
+)
+
+// hybridPlan picks the columns that hybrid generation fills locally instead
+// of asking the model: UUIDs, booleans, dates, timestamps and explicit
+// integer primary keys, which are numbered on from the table's largest.
+// Columns that take part in a foreign key, or that opts constrains in a way
+// the local generators don't follow, are left to the model, and so are
+// dates and timestamps when the time range asks for business hours. With
+// tableName set only that table is planned.
+func hybridPlan(ctx context.Context, tableName string, opts gemini.GenerateOptions) (generators.Plan, error) {
+	tables, err := database.GetStructuredSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fks, err := database.GetForeignKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	related := make(map[string]bool)
+	for _, fk := range fks {
+		related[fk.Table+"."+fk.Column] = true
+		related[fk.RefTable+"."+fk.RefColumn] = true
+	}
+
+	end := time.Now()
+	start := end.AddDate(-1, 0, 0)
+	localTimes := true
+	if opts.TimeRange != nil {
+		if start, end, err = opts.TimeRange.Bounds(); err != nil {
+			return nil, err
+		}
+		localTimes = !opts.TimeRange.BusinessHours
+	}
+
+	plan := make(generators.Plan)
+	for _, table := range tables {
+		if tableName != "" && table.Name != tableName {
+			continue
+		}
+		pk, err := database.GetPrimaryKey(ctx, table.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, col := range table.Columns {
+			key := table.Name + "." + col.Name
+			_, hasNullRate := opts.NullRates[key]
+			_, hasRange := opts.NumericRanges[key]
+			_, hasValues := opts.AllowedValues[key]
+			if col.Generated || related[key] || hasNullRate || hasRange || hasValues {
+				continue
+			}
+
+			var gen generators.Generator
+			switch {
+			case pk != nil && pk.Column == col.Name && pk.AutoIncrement:
+				// numbered by the database
+			case pk != nil && pk.Column == col.Name && pk.IsInteger():
+				next, ok := opts.KeyStarts[key]
+				if !ok {
+					max, err := database.MaxValue(ctx, table.Name, col.Name)
+					if err != nil {
+						return nil, fmt.Errorf("reading the largest %s: %v", key, err)
+					}
+					next = max + 1
+				}
+				gen = generators.Sequence(next)
+			case col.DataType == "date" || col.DataType == "timestamp without time zone" || col.DataType == "timestamp with time zone":
+				if localTimes {
+					gen = generators.ForType(col.DataType, start, end)
+				}
+			default:
+				gen = generators.ForType(col.DataType, start, end)
+			}
+			if gen != nil {
+				plan[table.Name] = append(plan[table.Name], generators.LocalColumn{Name: col.Name, Gen: gen})
+			}
+		}
+	}
+	return plan, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"genai/internal/database"
+)
+
+// isolatedTables reports the tables that take no part in any foreign key,
+// neither referencing another table nor being referenced, which are often
+// leftovers or missing a relationship.
+func (app *Application) isolatedTables(w http.ResponseWriter, r *http.Request) {
+	tables, err := database.FindIsolatedTables(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"isolatedTables": tables,
+	})
+}
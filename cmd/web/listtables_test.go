@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListTablesPaging(t *testing.T) {
+	app := newTestApp(t, &stubProvider{}, testSchema+`
+		CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES customers);
+	`)
+	app.Config.ListTablesPageSize = 1
+
+	get := func(url string) []byte {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		app.listTables(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d: %s", url, rec.Code, rec.Body)
+		}
+		return rec.Body.Bytes()
+	}
+
+	// Without limit or offset, every table as an array
+	var all []struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(get("/list-tables"), &all); err != nil {
+		t.Fatalf("unpaged response isn't an array: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("unpaged response lists %d tables, want 2", len(all))
+	}
+
+	for _, url := range []string{"/list-tables?limit=1", "/list-tables?offset=1"} {
+		var page struct {
+			Tables []struct {
+				Name string `json:"name"`
+			} `json:"tables"`
+			Total  int `json:"total"`
+			Limit  int `json:"limit"`
+			Offset int `json:"offset"`
+		}
+		if err := json.Unmarshal(get(url), &page); err != nil {
+			t.Fatalf("%s: paged response isn't an object: %v", url, err)
+		}
+		if len(page.Tables) != 1 || page.Total != 2 || page.Limit != 1 {
+			t.Errorf("%s: got %+v, want 1 of 2 tables with limit 1", url, page)
+		}
+	}
+}
+
+func TestPreviewOptionsRejectUnknownColumns(t *testing.T) {
+	app := newTestApp(t, &stubProvider{}, testSchema)
+
+	handlers := map[string]http.HandlerFunc{
+		"/list-tables?orderBy=nope":             app.listTables,
+		"/list-tables?where=nope=1":             app.listTables,
+		"/preview?table=customers&orderBy=nope": app.preview,
+		"/preview?table=customers&where=nope=1": app.preview,
+	}
+	for target, handler := range handlers {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status %d, want %d", target, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	app.preview(rec, httptest.NewRequest(http.MethodGet, "/preview?table=customers&orderBy=name&order=desc&where=id>0", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("valid options: status %d: %s", rec.Code, rec.Body)
+	}
+}
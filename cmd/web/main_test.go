@@ -0,0 +1,175 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"genai/internal/config"
+	"genai/internal/database"
+	"genai/internal/gemini"
+)
+
+func TestGeminiErrorStatus(t *testing.T) {
+	app := &Application{Config: &config.Config{LLMTimeout: time.Millisecond}}
+	callCtx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-callCtx.Done()
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"request budget", fmt.Errorf("reserving: %w", gemini.ErrOverRequestBudget), http.StatusPaymentRequired},
+		{"daily budget", gemini.ErrDailyBudgetExhausted, http.StatusTooManyRequests},
+		{"model call timed out", app.llmError(callCtx, errors.New("rpc error: context deadline exceeded")), http.StatusGatewayTimeout},
+		{"other error", errors.New("invalid API key"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := geminiErrorStatus(tt.err); got != tt.want {
+			t.Errorf("%s: geminiErrorStatus(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDownloadZipAppliesAllowList(t *testing.T) {
+	app := newTestApp(t, &stubProvider{}, testSchema+`
+		CREATE TABLE secrets (id INTEGER PRIMARY KEY, token TEXT);
+	`)
+	app.Config.QueryTables = []string{"customers"}
+
+	rec := httptest.NewRecorder()
+	app.downloadZip(rec, httptest.NewRequest(http.MethodGet, "/download-zip", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if want := []string{"customers.csv"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("zip entries = %v, want %v", names, want)
+	}
+}
+
+func TestTableHandlersRejectUnknownTables(t *testing.T) {
+	app := newTestApp(t, &stubProvider{}, testSchema)
+
+	handlers := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"download-csv", app.downloadCSV},
+		{"download-parquet", app.downloadParquet},
+		{"preview", app.preview},
+	}
+	for _, h := range handlers {
+		for _, table := range []string{"nope", "customers; DROP TABLE customers", `customers"--`} {
+			rec := httptest.NewRecorder()
+			h.handler(rec, httptest.NewRequest(http.MethodGet, "/"+h.name+"?table="+url.QueryEscape(table), nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("%s?table=%s: status %d, want %d", h.name, table, rec.Code, http.StatusBadRequest)
+			}
+		}
+	}
+
+	if !database.IsValidTableName(context.Background(), "customers") {
+		t.Fatal("the customers table is gone")
+	}
+	rec := httptest.NewRecorder()
+	app.downloadCSV(rec, httptest.NewRequest(http.MethodGet, "/download-csv?table=customers", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("download-csv?table=customers: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCheckTableAccess(t *testing.T) {
+	app := &Application{Config: &config.Config{QueryTables: []string{"customers"}}}
+	tests := []struct {
+		sql     string
+		allowed bool
+	}{
+		{"SELECT * FROM customers", true},
+		{"WITH c AS (SELECT * FROM customers) SELECT * FROM c", true},
+		{"SELECT * FROM secret", false},
+		{"WITH secret AS (SELECT * FROM secret) SELECT * FROM secret", false},
+		{"SELECT * FROM customers WHERE id IN (SELECT id FROM secret)", false},
+		{"SELECT * FROM other.customers", false},
+		{"SELECT query_to_xml('select * from secret', true, true, '')", false},
+	}
+	for _, tt := range tests {
+		if err := app.checkTableAccess(tt.sql); (err == nil) != tt.allowed {
+			t.Errorf("checkTableAccess(%q) = %v, want allowed %v", tt.sql, err, tt.allowed)
+		}
+	}
+}
+
+func TestTableEndpointsApplyAllowList(t *testing.T) {
+	app := newTestApp(t, &stubProvider{}, testSchema+`
+		CREATE TABLE secrets (id INTEGER PRIMARY KEY, token TEXT);
+	`)
+	app.Config.QueryTables = []string{"customers"}
+
+	handlers := map[string]http.HandlerFunc{
+		"/download-csv?table=secrets":     app.downloadCSV,
+		"/download-parquet?table=secrets": app.downloadParquet,
+		"/preview?table=secrets":          app.preview,
+	}
+	for target, handler := range handlers {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("GET %s: status %d, want %d", target, rec.Code, http.StatusForbidden)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	app.listTables(rec, httptest.NewRequest(http.MethodGet, "/list-tables", nil))
+	var tables []struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &tables); err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || tables[0].Name != "customers" {
+		t.Errorf("list-tables = %+v, want only customers", tables)
+	}
+}
+
+func TestDownloadFilenames(t *testing.T) {
+	app := newTestApp(t, &stubProvider{}, testSchema+`
+		CREATE TABLE "odd ""name"";x" (id INTEGER);
+	`)
+
+	rec := httptest.NewRecorder()
+	app.downloadCSV(rec, httptest.NewRequest(http.MethodGet, "/download-csv?table=customers&dbName=true&timestamp=false", nil))
+	if got, want := rec.Header().Get("Content-Disposition"), "attachment; filename=test_customers.csv"; got != want {
+		t.Errorf("Content-Disposition = %q, want %q", got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	app.downloadCSV(rec, httptest.NewRequest(http.MethodGet, `/download-csv?timestamp=false&table=`+url.QueryEscape(`odd "name";x`), nil))
+	_, params, err := mime.ParseMediaType(rec.Header().Get("Content-Disposition"))
+	if err != nil {
+		t.Fatalf("Content-Disposition %q: %v", rec.Header().Get("Content-Disposition"), err)
+	}
+	if got, want := params["filename"], `odd "name";x.csv`; got != want {
+		t.Errorf("filename = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// notFound is the mux fallback. Browsers get a styled HTML page, API clients
+// a JSON error.
+func (app *Application) notFound(w http.ResponseWriter, r *http.Request) {
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		ts, err := template.ParseFiles("ui/html/404.html")
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		ts.Execute(w, map[string]string{"Path": r.URL.Path})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": "not found",
+		"path":  r.URL.Path,
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"genai/internal/database"
+	"genai/internal/parquet"
+)
+
+func TestParquetTypeSQLite(t *testing.T) {
+	app := newTestApp(t, &stubProvider{}, `
+		CREATE TABLE measurements (id INTEGER, reading REAL, label TEXT, taken DATETIME);
+		INSERT INTO measurements VALUES (1, 2.5, 'a', '2024-01-02 03:04:05');
+	`)
+
+	rows, err := database.DB.Query("SELECT * FROM measurements")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []parquet.ColumnType{parquet.Int64, parquet.Double, parquet.String, parquet.String}
+	for i, ct := range colTypes {
+		if got := parquetType(ct); got != want[i] {
+			t.Errorf("parquetType(%s %s) = %v, want %v", ct.Name(), ct.DatabaseTypeName(), got, want[i])
+		}
+	}
+	rows.Close()
+
+	rec := httptest.NewRecorder()
+	app.downloadParquet(rec, httptest.NewRequest(http.MethodGet, "/download-parquet?table=measurements", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if body := rec.Body.Bytes(); !bytes.HasPrefix(body, []byte("PAR1")) || !bytes.HasSuffix(body, []byte("PAR1")) {
+		t.Errorf("download is not a complete Parquet file")
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"genai/internal/database"
+)
+
+// maxTextCell caps the width of a column in text table previews.
+const maxTextCell = 40
+
+// preview returns the preview rows of ?table, with the same ordering and
+// filter options as /list-tables. ?format=table, or an Accept header asking
+// for text/plain, returns them as an aligned text table for terminals
+// instead of JSON.
+func (app *Application) preview(w http.ResponseWriter, r *http.Request) {
+	tableName := r.URL.Query().Get("table")
+	if tableName == "" {
+		http.Error(w, "Table name is required", http.StatusBadRequest)
+		return
+	}
+	if !database.IsValidTableName(r.Context(), tableName) {
+		http.Error(w, fmt.Sprintf("Unknown table %s", tableName), http.StatusBadRequest)
+		return
+	}
+	if !app.tableAllowed(tableName) {
+		http.Error(w, fmt.Sprintf("Table %s is not allowed", tableName), http.StatusForbidden)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "text/plain") {
+		format = "table"
+	}
+	if format != "" && format != "json" && format != "table" {
+		http.Error(w, "format must be json or table", http.StatusBadRequest)
+		return
+	}
+
+	opts, err := parsePreviewOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cols, err := database.GetColumns(r.Context(), tableName)
+	if err != nil {
+		http.Error(w, "Error fetching columns", http.StatusInternalServerError)
+		return
+	}
+	rows, err := app.fetchingTableData(r.Context(), tableName, opts)
+	if errors.Is(err, errPreviewColumn) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching data: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if format == "table" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(formatTextTable(cols, rows)))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"table":   tableName,
+		"columns": cols,
+		"rows":    append([]map[string]interface{}{}, rows...),
+	})
+}
+
+// formatTextTable renders rows as a table with aligned, boxed columns in the
+// order of cols, followed by the row count, like psql's output.
+func formatTextTable(cols []string, rows []map[string]interface{}) string {
+	cells := make([][]string, len(rows))
+	widths := make([]int, len(cols))
+	for i, col := range cols {
+		widths[i] = utf8.RuneCountInString(col)
+	}
+	for r, row := range rows {
+		cells[r] = make([]string, len(cols))
+		for i, col := range cols {
+			cell := truncateCell(textCell(row[col]), maxTextCell)
+			cells[r][i] = cell
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+
+	var sb strings.Builder
+	border := func() {
+		for _, width := range widths {
+			sb.WriteString("+" + strings.Repeat("-", width+2))
+		}
+		sb.WriteString("+\n")
+	}
+	line := func(values []string) {
+		for i, v := range values {
+			sb.WriteString("| " + v + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(v)) + " ")
+		}
+		sb.WriteString("|\n")
+	}
+
+	border()
+	line(cols)
+	border()
+	for _, row := range cells {
+		line(row)
+	}
+	if len(cells) > 0 {
+		border()
+	}
+	if len(rows) == 1 {
+		sb.WriteString("(1 row)\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("(%d rows)\n", len(rows)))
+	}
+	return sb.String()
+}
+
+// textCell renders a preview value on a single line, with NULL for nil.
+func textCell(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "NULL"
+	case time.Time:
+		return x.Format(time.RFC3339)
+	}
+	return strings.Join(strings.Fields(formatValue(v)), " ")
+}
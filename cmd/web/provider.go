@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"genai/internal/config"
+	"genai/internal/gemini"
+	"genai/internal/openai"
+)
+
+// LLMProvider is a model API the handlers generate and translate SQL with.
+// LLM_PROVIDER picks the implementation.
+type LLMProvider interface {
+	// CheckOptions reports generation options the provider can't honor.
+	CheckOptions(opts gemini.GenerateOptions) error
+	GenerateDataSQL(ctx context.Context, schema string, opts gemini.GenerateOptions) (*gemini.Generation, error)
+	NaturalLanguageToSQL(ctx context.Context, schema, prompt string) (string, bool, error)
+	NaturalLanguageToSQLWithModel(ctx context.Context, modelName, schema, prompt string) (string, bool, error)
+	GenerateJSONRecords(ctx context.Context, jsonSchema string, count int, temperature float32) (json.RawMessage, error)
+	ExplainSQL(ctx context.Context, sql string) (string, error)
+	FixSQL(ctx context.Context, schema, stmt, errMsg string) (string, error)
+	DescribeTables(ctx context.Context, schema string) (map[string]string, error)
+	// GenerationPrompt and QueryPrompt return the system instruction and
+	// prompt that GenerateDataSQL and NaturalLanguageToSQL would send.
+	GenerationPrompt(schema string, opts gemini.GenerateOptions) (system, prompt string)
+	QueryPrompt(schema, prompt string, now time.Time) (system, input string)
+	CountTokens(ctx context.Context, text string) (int32, error)
+	// Ping checks that the API accepts the configured key.
+	Ping(ctx context.Context) error
+	Close()
+}
+
+// newLLMProvider creates the provider cfg selects, charging its calls to
+// budget.
+func newLLMProvider(cfg *config.Config, budget *gemini.TokenBudget) (LLMProvider, error) {
+	if cfg.LLMProvider == "openai" {
+		return openai.NewClient(cfg, budget), nil
+	}
+	return gemini.NewClient(cfg, budget)
+}
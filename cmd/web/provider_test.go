@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"genai/internal/config"
+	"genai/internal/database"
+	"genai/internal/gemini"
+	"genai/internal/generators"
+)
+
+// stubProvider is an LLMProvider that answers from its fields instead of
+// calling a model, for handler tests.
+type stubProvider struct {
+	// checkErr is returned by CheckOptions.
+	checkErr error
+	// sql is returned by GenerateDataSQL, NaturalLanguageToSQL and FixSQL.
+	sql string
+	// calls counts the model calls made.
+	calls int
+	// slowModel names a model whose calls wait until their context ends.
+	slowModel string
+	// slow makes FixSQL and DescribeTables wait until their context ends.
+	slow bool
+}
+
+func (p *stubProvider) CheckOptions(opts gemini.GenerateOptions) error {
+	return p.checkErr
+}
+
+func (p *stubProvider) GenerateDataSQL(ctx context.Context, schema string, opts gemini.GenerateOptions) (*gemini.Generation, error) {
+	p.calls++
+	return &gemini.Generation{SQL: p.sql}, nil
+}
+
+func (p *stubProvider) NaturalLanguageToSQL(ctx context.Context, schema, prompt string) (string, bool, error) {
+	p.calls++
+	_, _, isChart := gemini.ParseChartMarker(p.sql)
+	return p.sql, isChart, nil
+}
+
+func (p *stubProvider) NaturalLanguageToSQLWithModel(ctx context.Context, modelName, schema, prompt string) (string, bool, error) {
+	if modelName == p.slowModel {
+		<-ctx.Done()
+		return "", false, ctx.Err()
+	}
+	return p.NaturalLanguageToSQL(ctx, schema, prompt)
+}
+
+func (p *stubProvider) GenerateJSONRecords(ctx context.Context, jsonSchema string, count int, temperature float32) (json.RawMessage, error) {
+	p.calls++
+	return json.RawMessage("[]"), nil
+}
+
+func (p *stubProvider) ExplainSQL(ctx context.Context, sql string) (string, error) {
+	p.calls++
+	return "stub explanation", nil
+}
+
+func (p *stubProvider) FixSQL(ctx context.Context, schema, stmt, errMsg string) (string, error) {
+	p.calls++
+	if p.slow {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	return p.sql, nil
+}
+
+func (p *stubProvider) DescribeTables(ctx context.Context, schema string) (map[string]string, error) {
+	p.calls++
+	if p.slow {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return map[string]string{}, nil
+}
+
+func (p *stubProvider) GenerationPrompt(schema string, opts gemini.GenerateOptions) (string, string) {
+	return "stub generation system", "stub generation prompt\n" + schema
+}
+
+func (p *stubProvider) QueryPrompt(schema, prompt string, now time.Time) (string, string) {
+	return "stub query system", prompt
+}
+
+func (p *stubProvider) CountTokens(ctx context.Context, text string) (int32, error) {
+	return int32(len(text) / 4), nil
+}
+
+func (p *stubProvider) Ping(ctx context.Context) error { return nil }
+
+func (p *stubProvider) Close() {}
+
+// newTestApp returns an Application backed by llm and a new SQLite database
+// holding schema, and restores the database package afterwards.
+func newTestApp(t *testing.T, llm LLMProvider, schema string) *Application {
+	t.Helper()
+	prevDB, prevDialect := database.DB, database.ActiveDialect()
+	t.Cleanup(func() {
+		database.DB.Close()
+		database.DB = prevDB
+		database.SetDialect(prevDialect)
+	})
+
+	if err := database.InitDB("sqlite:"+filepath.Join(t.TempDir(), "test.db"), "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.DB.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &Application{
+		Config: &config.Config{
+			DatabaseDialect: "sqlite",
+			LLMTimeout:      time.Minute,
+			RequestTimeout:  time.Minute,
+			QueryTimeout:    time.Minute,
+			QueryMaxRows:    config.DefaultQueryMaxRows,
+		},
+		QueryCache:  newQueryCache(),
+		ZipProgress: newZipProgressStore(),
+	}
+	app.llm.Store(&llm)
+	return app
+}
+
+const testSchema = `
+	CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT NOT NULL);
+	INSERT INTO customers (id, name) VALUES (1, 'Ann'), (2, 'Bob');
+`
+
+func TestGenerateDataRejectsUnsupportedOptions(t *testing.T) {
+	llm := &stubProvider{checkErr: errors.New("topK is not supported by the stub provider")}
+	app := newTestApp(t, llm, testSchema)
+
+	req := httptest.NewRequest(http.MethodPost, "/generate-data", strings.NewReader(`{"topK": 40}`))
+	rec := httptest.NewRecorder()
+	app.generateData(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), "topK") {
+		t.Errorf("body %q doesn't name the option", rec.Body)
+	}
+	if llm.calls != 0 {
+		t.Errorf("the model was called %d times", llm.calls)
+	}
+}
+
+func TestDebugPromptUsesProvider(t *testing.T) {
+	app := newTestApp(t, &stubProvider{}, testSchema)
+
+	req := httptest.NewRequest(http.MethodPost, "/debug/prompt", strings.NewReader(`{"kind": "generate"}`))
+	rec := httptest.NewRecorder()
+	app.debugPrompt(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		SystemInstruction string `json:"systemInstruction"`
+		Prompt            string `json:"prompt"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.SystemInstruction != "stub generation system" || !strings.Contains(resp.Prompt, "customers") {
+		t.Errorf("got %+v, want the stub provider's prompt for the schema", resp)
+	}
+}
+
+func TestQueryCompareValidatesModels(t *testing.T) {
+	app := newTestApp(t, &stubProvider{sql: "SELECT 1"}, testSchema)
+
+	for _, models := range []string{`["a", ""]`, `["a", " "]`, `["a", "b", "a"]`} {
+		rec := httptest.NewRecorder()
+		body := `{"prompt": "count customers", "models": ` + models + `}`
+		app.queryCompare(rec, httptest.NewRequest(http.MethodPost, "/query-compare", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("models %s: status %d, want %d", models, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestQueryCompareTimesOutEachModel(t *testing.T) {
+	app := newTestApp(t, &stubProvider{sql: "SELECT 1", slowModel: "slow"}, testSchema)
+	app.Config.LLMTimeout = 20 * time.Millisecond
+
+	rec := httptest.NewRecorder()
+	body := `{"prompt": "count customers", "models": ["slow", "fast"]}`
+	app.queryCompare(rec, httptest.NewRequest(http.MethodPost, "/query-compare", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp struct {
+		Results []struct {
+			Model string `json:"model"`
+			SQL   string `json:"sql"`
+			Error string `json:"error"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 2 {
+		t.Fatalf("got %d results, want 2", len(resp.Results))
+	}
+	if slow := resp.Results[0]; !strings.Contains(slow.Error, "did not respond") {
+		t.Errorf("slow model error = %q, want a timeout", slow.Error)
+	}
+	if fast := resp.Results[1]; fast.SQL != "SELECT 1" || fast.Error != "" {
+		t.Errorf("fast model = %+v, want its SQL", fast)
+	}
+}
+
+func TestSlowCorrectionAndDescriptionsTimeOut(t *testing.T) {
+	llm := &stubProvider{sql: "INSERT INTO customers (id, nickname) VALUES (3, 'Cy');", slow: true}
+	app := newTestApp(t, llm, testSchema)
+	app.Config.LLMTimeout = 20 * time.Millisecond
+	app.Config.FixUndefinedColumns = true
+	app.Descriptions = newDescriptionCache()
+	app.Generators = generators.NewRegistry()
+
+	rec := httptest.NewRecorder()
+	app.generateData(rec, httptest.NewRequest(http.MethodPost, "/generate-data", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("generate-data: status %d, want %d: %s", rec.Code, http.StatusGatewayTimeout, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	app.dataDictionary(rec, httptest.NewRequest(http.MethodGet, "/data-dictionary?describe=true", nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("data-dictionary: status %d, want %d: %s", rec.Code, http.StatusGatewayTimeout, rec.Body)
+	}
+}
+
+func TestThinkingBudgetRejected(t *testing.T) {
+	app := newTestApp(t, &gemini.Client{}, testSchema)
+
+	// Negative budgets fail validation; any other budget isn't supported
+	tests := []struct {
+		target  string
+		handler http.HandlerFunc
+		body    string
+		want    int
+	}{
+		{"/query", app.query, `{"prompt": "count customers", "thinkingBudget": 1024}`, http.StatusBadRequest},
+		{"/query", app.query, `{"prompt": "count customers", "thinkingBudget": -1}`, http.StatusUnprocessableEntity},
+		{"/generate-data", app.generateData, `{"thinkingBudget": 1024}`, http.StatusBadRequest},
+		{"/generate-data", app.generateData, `{"thinkingBudget": -1}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		tt.handler(rec, httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("POST %s %s: status %d, want %d: %s", tt.target, tt.body, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"genai/internal/database"
+)
+
+// maxCachedResults bounds the query result cache; it is reset once full.
+const maxCachedResults = 200
+
+// queryCache holds query results keyed by the executed SQL and the versions
+// of the tables it reads, so an entry is never served after a write to one of
+// those tables.
+type queryCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedResult
+}
+
+type cachedResult struct {
+	cols []string
+	rows []map[string]interface{}
+}
+
+func newQueryCache() *queryCache {
+	return &queryCache{entries: make(map[string]cachedResult)}
+}
+
+// key builds the cache key for a query of the context's tenant and any
+// options that shape the result.
+func (c *queryCache) key(ctx context.Context, sql string, options string) string {
+	// Table versions are kept by bare name, as writes record them
+	tables := database.ReferencedTables(sql)
+	for i, name := range tables {
+		tables[i] = database.UnqualifiedName(name)
+	}
+	return options + "\x00" + database.VersionKey(ctx, tables) + "\x00" + sql
+}
+
+func (c *queryCache) get(key string) ([]string, []map[string]interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return entry.cols, entry.rows, ok
+}
+
+func (c *queryCache) put(key string, cols []string, rows []map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCachedResults {
+		c.entries = make(map[string]cachedResult)
+	}
+	c.entries[key] = cachedResult{cols: cols, rows: rows}
+}
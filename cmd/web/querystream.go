@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"genai/internal/database"
+)
+
+// streamBatchSize is how many rows each "rows" event of /query/stream carries.
+const streamBatchSize = 100
+
+// queryStream is the server-sent events variant of query for large results.
+// It sends the generated SQL as an "sql" event, then the rows in "rows"
+// events as they are scanned, and finishes with a "done" event carrying the
+// row count. Failures after the stream has started arrive as an "error" event.
+func (app *Application) queryStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stream, ok := newEventStream(w)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	var req struct {
+		Prompt string `json:"prompt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	omitNulls, err := parseNullsOption(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The stream itself may legitimately run long, but translating the
+	// question is bounded like any other query request
+	translateCtx, cancel := context.WithTimeout(r.Context(), app.Config.QueryTimeout)
+	q, status, err := app.translateQuery(translateCtx, req.Prompt)
+	cancel()
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	stream.send("sql", map[string]interface{}{
+		"sql":       q.SQL,
+		"isChart":   q.IsChart,
+		"chartType": q.ChartType,
+	})
+
+	rows, done, err := database.QueryReadOnly(r.Context(), q.SQL)
+	if err != nil {
+		stream.send("error", map[string]string{"error": fmt.Sprintf("Query execution error: %v", err)})
+		return
+	}
+	defer done()
+
+	cols, _ := rows.Columns()
+	arrays := arrayColumns(rows)
+	batch := make([]map[string]interface{}, 0, streamBatchSize)
+	count := 0
+	for rows.Next() {
+		m, err := scanRow(rows, cols, arrays, omitNulls)
+		if err != nil {
+			continue
+		}
+		batch = append(batch, m)
+		count++
+		if len(batch) == streamBatchSize {
+			stream.send("rows", batch)
+			batch = batch[:0]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		stream.send("error", map[string]string{"error": fmt.Sprintf("Query execution error: %v", err)})
+		return
+	}
+	if len(batch) > 0 {
+		stream.send("rows", batch)
+	}
+
+	stream.send("done", map[string]interface{}{"columns": cols, "rowCount": count})
+}
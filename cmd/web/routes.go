@@ -0,0 +1,34 @@
+package main
+
+import "net/http"
+
+// apiVersion is the path prefix of the current API version. Incompatible
+// changes go under a new prefix, leaving existing clients on this one.
+const apiVersion = "/v1"
+
+// registerAPI registers the API routes on mux under prefix.
+func (app *Application) registerAPI(mux *http.ServeMux, prefix string) {
+	mux.HandleFunc(prefix+"/upload-ddl", withTimeout(app.Config.RequestTimeout, app.uploadDDL))
+	mux.HandleFunc(prefix+"/alter-schema", withTimeout(app.Config.RequestTimeout, app.alterSchema))
+	mux.HandleFunc(prefix+"/generate-data", withStreamTimeout(app.Config.GenerateTimeout, app.generateData))
+	mux.HandleFunc(prefix+"/generate-from-json-schema", withTimeout(app.Config.GenerateTimeout, app.generateFromJSONSchema))
+	mux.HandleFunc(prefix+"/query", withTimeout(app.Config.QueryTimeout, app.query))
+	mux.HandleFunc(prefix+"/query/compare", withTimeout(app.Config.QueryTimeout, app.queryCompare))
+	mux.HandleFunc(prefix+"/query/stream", app.queryStream)
+	mux.HandleFunc(prefix+"/query-history", withTimeout(app.Config.RequestTimeout, app.queryHistory))
+	mux.HandleFunc(prefix+"/run-sql", withTimeout(app.Config.QueryTimeout, app.runSQL))
+	mux.HandleFunc(prefix+"/list-tables", withTimeout(app.Config.RequestTimeout, app.listTables))
+	mux.HandleFunc(prefix+"/preview", withTimeout(app.Config.RequestTimeout, app.preview))
+	mux.HandleFunc(prefix+"/data-dictionary", withTimeout(app.Config.QueryTimeout, app.dataDictionary))
+	mux.HandleFunc(prefix+"/isolated-tables", withTimeout(app.Config.RequestTimeout, app.isolatedTables))
+	mux.HandleFunc(prefix+"/status", withTimeout(app.Config.RequestTimeout, app.status))
+	mux.HandleFunc(prefix+"/download-csv", app.downloadCSV)
+	mux.HandleFunc(prefix+"/download-zip", app.downloadZip)
+	mux.HandleFunc(prefix+"/download-zip/progress", withTimeout(app.Config.RequestTimeout, app.downloadZipProgress))
+	mux.HandleFunc(prefix+"/download-parquet", app.downloadParquet)
+	mux.HandleFunc(prefix+"/export", app.export)
+	mux.HandleFunc(prefix+"/export/sheets", withTimeout(app.Config.QueryTimeout, app.exportSheets))
+	mux.HandleFunc(prefix+"/config", withTimeout(app.Config.RequestTimeout, app.requireAdmin(app.showConfig)))
+	mux.HandleFunc(prefix+"/admin/rotate-key", withTimeout(app.Config.RequestTimeout, app.requireAdmin(app.rotateKey)))
+	mux.HandleFunc(prefix+"/debug/prompt", withTimeout(app.Config.RequestTimeout, app.requireAdmin(app.debugPrompt)))
+}
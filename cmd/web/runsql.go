@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// runSQL executes a user-written SELECT. With a chartType, the result is
+// tagged for charting like the natural-language path, provided it has a
+// numeric column to plot.
+func (app *Application) runSQL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		SQL       string `json:"sql"`
+		ChartType string `json:"chartType"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.ChartType != "" && !chartTypes[req.ChartType] {
+		http.Error(w, "Invalid chartType, expected bar, pie, line or doughnut", http.StatusBadRequest)
+		return
+	}
+
+	if reason := unsafeReason(req.SQL); reason != "" {
+		http.Error(w, fmt.Sprintf("Unsafe query. Operation blocked: %s.", reason), http.StatusForbidden)
+		return
+	}
+	if err := app.checkTableAccess(req.SQL); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	cols, result, err := app.runQuery(r.Context(), req.SQL, false)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Query execution error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"sql":       req.SQL,
+		"result":    result,
+		"isChart":   req.ChartType != "",
+		"chartType": req.ChartType,
+	}
+
+	if req.ChartType != "" {
+		labelColumn, valueColumn, err := chartAxes(cols, result)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		response["labelColumn"] = labelColumn
+		response["valueColumn"] = valueColumn
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// exportSheets writes the result of a user-written SELECT to a Google Sheet
+// and returns its URL. It is only available when GOOGLE_SHEETS_CREDENTIALS is
+// set. Without a spreadsheetId a new spreadsheet is created.
+func (app *Application) exportSheets(w http.ResponseWriter, r *http.Request) {
+	if app.Sheets == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		SQL           string `json:"sql" validate:"required"`
+		SpreadsheetID string `json:"spreadsheetId"`
+		Title         string `json:"title"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if errs := validate(&req); len(errs) > 0 {
+		writeFieldErrors(w, errs)
+		return
+	}
+	if req.Title == "" {
+		req.Title = fmt.Sprintf("Query export %s", time.Now().UTC().Format("2006-01-02 15:04"))
+	}
+
+	if reason := unsafeReason(req.SQL); reason != "" {
+		http.Error(w, fmt.Sprintf("Unsafe query. Operation blocked: %s.", reason), http.StatusForbidden)
+		return
+	}
+	if err := app.checkTableAccess(req.SQL); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	cols, result, err := app.runQuery(r.Context(), req.SQL, false)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Query execution error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	url, err := app.Sheets.Export(r.Context(), req.SpreadsheetID, req.Title, cols, result)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Google Sheets error: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"url":  url,
+		"rows": len(result),
+	})
+}
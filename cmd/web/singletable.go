@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"genai/internal/database"
+	"genai/internal/gemini"
+)
+
+// maxParentSamples caps how many existing parent keys are offered to the
+// model for each foreign key in single-table generation.
+const maxParentSamples = 50
+
+// maxLookupValues is the largest number of keys a referenced table may have
+// to be treated as a lookup table, whose complete key set is listed in the
+// prompt rather than a sample.
+const maxLookupValues = 100
+
+// singleTableSchema renders the generation schema for just one table and
+// sets the options that keep its rows consistent with the data already
+// there. Foreign key columns may only take the keys in each parent table,
+// all of them for a lookup table and a sample otherwise, since the parents
+// aren't generated alongside it. A serial primary key is left out for the
+// database to number; an explicit integer one continues from its current
+// maximum. On failure it also returns the HTTP status to reply with.
+func singleTableSchema(ctx context.Context, tableName string, opts *gemini.GenerateOptions) (string, int, error) {
+	tables, err := database.GetStructuredSchema(ctx)
+	if err != nil {
+		return "", http.StatusInternalServerError, errors.New("Error fetching schema")
+	}
+	i := slices.IndexFunc(tables, func(t database.Table) bool { return t.Name == tableName })
+	if i < 0 {
+		return "", http.StatusBadRequest, fmt.Errorf("Unknown table %s", tableName)
+	}
+	table := database.InsertableColumns(tables[i : i+1])[0]
+
+	pk, err := database.GetPrimaryKey(ctx, tableName)
+	if err != nil {
+		return "", http.StatusInternalServerError, errors.New("Error fetching primary key")
+	}
+	switch {
+	case pk == nil:
+	case pk.AutoIncrement:
+		table.Columns = slices.DeleteFunc(slices.Clone(table.Columns), func(c database.Column) bool { return c.Name == pk.Column })
+	case pk.IsInteger():
+		max, err := database.MaxValue(ctx, tableName, pk.Column)
+		if err != nil {
+			return "", http.StatusInternalServerError, fmt.Errorf("Error reading the largest %s: %v", pk.Column, err)
+		}
+		opts.KeyStarts = map[string]int64{tableName + "." + pk.Column: max + 1}
+	}
+
+	fks, err := database.GetForeignKeys(ctx)
+	if err != nil {
+		return "", http.StatusInternalServerError, errors.New("Error fetching foreign keys")
+	}
+
+	allowed := make(map[string][]string)
+	for _, fk := range fks {
+		if fk.Table != tableName || fk.RefTable == tableName {
+			continue
+		}
+		values, lookup, err := database.LookupValues(ctx, fk.RefTable, fk.RefColumn, maxLookupValues)
+		if err == nil && !lookup {
+			values, err = database.SampleValues(ctx, fk.RefTable, fk.RefColumn, maxParentSamples)
+		}
+		if err != nil {
+			return "", http.StatusInternalServerError, fmt.Errorf("Error sampling %s.%s: %v", fk.RefTable, fk.RefColumn, err)
+		}
+		if len(values) == 0 {
+			return "", http.StatusBadRequest, fmt.Errorf("%s references %s, which has no rows yet; generate data for it first", tableName, fk.RefTable)
+		}
+		allowed[fk.Table+"."+fk.Column] = values
+	}
+
+	opts.AllowedValues = allowed
+
+	return database.FormatSchema([]database.Table{table}, fks), 0, nil
+}
+
+// lookupTableValues returns, for every foreign key column that references an
+// already populated lookup table, the complete set of that table's keys, so
+// generated rows only use statuses, categories and the like that exist.
+func lookupTableValues(ctx context.Context) (map[string][]string, error) {
+	fks, err := database.GetForeignKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[string][]string)
+	for _, fk := range fks {
+		if fk.RefTable == fk.Table {
+			continue
+		}
+		values, lookup, err := database.LookupValues(ctx, fk.RefTable, fk.RefColumn, maxLookupValues)
+		if err != nil {
+			return nil, err
+		}
+		if lookup && len(values) > 0 {
+			allowed[fk.Table+"."+fk.Column] = values
+		}
+	}
+	return allowed, nil
+}
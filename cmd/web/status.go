@@ -0,0 +1,22 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"genai/internal/database"
+)
+
+// status reports which database the server talks to, so clients can adapt
+// to its dialect and version.
+func (app *Application) status(w http.ResponseWriter, r *http.Request) {
+	info, err := database.GetServerInfo(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+
+	"genai/internal/database"
+)
+
+// withTenant routes a request's database work to the tenant named in the
+// X-Tenant-ID header. Requests without the header use the default database;
+// unknown tenants are rejected rather than falling back to it.
+func (app *Application) withTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Tenant-ID")
+		if id == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !database.HasTenant(id) {
+			http.Error(w, "Unknown tenant", http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(database.WithTenant(r.Context(), id)))
+	})
+}
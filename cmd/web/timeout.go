@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// withTimeout bounds a handler to d. The request context carries the
+// deadline, so database and Gemini calls made with r.Context() are cancelled
+// with it, and the client gets a 503 instead of waiting on a stuck handler.
+//
+// The response is buffered until the handler returns, so streaming exports
+// are registered without it.
+func withTimeout(d time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return http.TimeoutHandler(next, d, "Request timed out").ServeHTTP
+}
+
+// withStreamTimeout is withTimeout for handlers that can also answer with
+// server-sent events. Those requests bypass the buffering so events reach
+// the client as they are sent, and are only bounded by a context deadline.
+func withStreamTimeout(d time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	buffered := withTimeout(d, next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !wantsEventStream(r) {
+			buffered(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// partialResultMargin is how long before the request deadline work that can
+// return partial results is stopped, leaving time to write them before
+// withTimeout answers 503.
+const partialResultMargin = time.Second
+
+// partialResultContext derives a context that ends partialResultMargin
+// before ctx's deadline, if it has one.
+func partialResultContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline.Add(-partialResultMargin))
+}
+
+// llmContext derives the context for one model call, bounded by
+// LLM_TIMEOUT.
+func (app *Application) llmContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, app.Config.LLMTimeout)
+}
+
+// llmError replaces the error of a model call that ran past its llmContext
+// deadline with one saying so, which geminiErrorStatus maps to 504. Other
+// errors are returned unchanged.
+func (app *Application) llmError(callCtx context.Context, err error) error {
+	if err != nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("the model did not respond within %s: %w", app.Config.LLMTimeout, context.DeadlineExceeded)
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FieldError describes why one field of a request body was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validate checks a decoded request struct against the rules in its
+// `validate` struct tags and returns one FieldError per failing field,
+// named by its JSON key. Rules are comma separated:
+//
+//	required      the field must not be the zero value
+//	min=N, max=N  bounds for numbers, or for the length of strings and slices
+//	oneof=a b c   the string must be one of the listed values
+//
+// Nil pointers skip every rule but required, so optional fields can be
+// bounded without being mandatory.
+func validate(req interface{}) []FieldError {
+	v := reflect.Indirect(reflect.ValueOf(req))
+	t := v.Type()
+
+	var errs []FieldError
+	for i := 0; i < t.NumField(); i++ {
+		rules := t.Field(i).Tag.Get("validate")
+		if rules == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		field := v.Field(i)
+		for _, rule := range strings.Split(rules, ",") {
+			if msg := checkRule(field, rule); msg != "" {
+				errs = append(errs, FieldError{Field: name, Message: msg})
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// checkRule applies one rule to a field value and returns the failure
+// message, or "" if the value passes.
+func checkRule(field reflect.Value, rule string) string {
+	key, arg, _ := strings.Cut(rule, "=")
+	if key == "required" {
+		if field.IsZero() {
+			return "is required"
+		}
+		return ""
+	}
+	if field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return ""
+		}
+		field = field.Elem()
+	}
+
+	switch key {
+	case "min", "max":
+		bound, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad %s bound %q", key, arg))
+		}
+		var n float64
+		var what string
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = float64(field.Int())
+		case reflect.Float32, reflect.Float64:
+			n = field.Float()
+		case reflect.String, reflect.Slice, reflect.Map:
+			n, what = float64(field.Len()), " in length"
+		default:
+			panic(fmt.Sprintf("validate: %s does not apply to %s", key, field.Kind()))
+		}
+		if key == "min" && n < bound {
+			return fmt.Sprintf("must be at least %s%s", arg, what)
+		}
+		if key == "max" && n > bound {
+			return fmt.Sprintf("must be at most %s%s", arg, what)
+		}
+	case "oneof":
+		allowed := strings.Fields(arg)
+		if s := field.String(); s != "" && !slices.Contains(allowed, s) {
+			return fmt.Sprintf("must be one of %s", strings.Join(allowed, ", "))
+		}
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", rule))
+	}
+	return ""
+}
+
+// writeFieldErrors replies 422 with the fields that failed validation.
+func writeFieldErrors(w http.ResponseWriter, errs []FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "Validation failed",
+		"fields": errs,
+	})
+}
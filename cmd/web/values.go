@@ -0,0 +1,66 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// formatValue renders a scanned value for CSV cells. lib/pq returns
+// arrays, numerics and other types it doesn't decode as []byte holding their
+// text form, e.g. {a,b} for an array, which %v would print as a byte slice.
+func formatValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(x)
+	default:
+		return fmt.Sprintf("%v", x)
+	}
+}
+
+// maxCellLimit is the largest ?maxCell accepted, Excel's limit on the
+// characters in a cell.
+const maxCellLimit = 32767
+
+// parseMaxCell reads the ?maxCell=N export option, the length beyond which
+// cell values are truncated. It returns 0, meaning no truncation, when unset.
+func parseMaxCell(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("maxCell")
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > maxCellLimit {
+		return 0, fmt.Errorf("maxCell must be between 1 and %d", maxCellLimit)
+	}
+	return n, nil
+}
+
+// truncateCell shortens s to at most max characters, ending it with an
+// ellipsis when anything was cut. A max of 0 leaves s unchanged.
+func truncateCell(s string, max int) string {
+	if max == 0 || utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
+
+// arrayColumns reports which result columns are PostgreSQL arrays, whose
+// type names lib/pq reports with a leading underscore, e.g. _TEXT.
+func arrayColumns(rows *sql.Rows) []bool {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil
+	}
+	arrays := make([]bool, len(types))
+	for i, ct := range types {
+		arrays[i] = strings.HasPrefix(ct.DatabaseTypeName(), "_")
+	}
+	return arrays
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{[]byte("{red,\"light blue\"}"), "{red,\"light blue\"}"}, // a tags text[] value from lib/pq
+		{[]byte("12.50"), "12.50"},
+		{int64(7), "7"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := formatValue(tt.value); got != tt.want {
+			t.Errorf("formatValue(%#v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
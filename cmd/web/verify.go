@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"genai/internal/database"
+)
+
+// maxVerifyRows caps how many problem rows are reported per check.
+const maxVerifyRows = 20
+
+// verifyResult is the outcome of one post-generation check. A check selects
+// the problems it finds, so it passes when it returns no rows.
+type verifyResult struct {
+	SQL      string                   `json:"sql"`
+	Passed   bool                     `json:"passed"`
+	Problems []map[string]interface{} `json:"problems,omitempty"`
+	Error    string                   `json:"error,omitempty"`
+}
+
+// verifyTables runs a check for each table, and for every table in checks:
+// the caller's SELECT when there is one, otherwise
+// database.VerificationQuery. It reads from the
+// primary so a lagging replica can't hide the rows just committed.
+func (app *Application) verifyTables(ctx context.Context, tables []string, checks map[string]string) map[string]verifyResult {
+	// Tables missing from the schema are reported per check below
+	schema, _ := database.GetStructuredSchema(ctx)
+
+	tables = slices.Clone(tables)
+	for tableName := range checks {
+		if !slices.Contains(tables, tableName) {
+			tables = append(tables, tableName)
+		}
+	}
+
+	results := make(map[string]verifyResult)
+	for _, tableName := range tables {
+		check, ok := checks[tableName]
+		if !ok {
+			i := slices.IndexFunc(schema, func(t database.Table) bool { return t.Name == tableName })
+			if i < 0 {
+				results[tableName] = verifyResult{Error: "table not found in schema"}
+				continue
+			}
+			check = database.VerificationQuery(schema[i])
+		}
+		results[tableName] = app.runCheck(ctx, check)
+	}
+	return results
+}
+
+func (app *Application) runCheck(ctx context.Context, check string) verifyResult {
+	result := verifyResult{SQL: check}
+
+	rows, err := database.Primary(ctx).QueryContext(ctx, check)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer rows.Close()
+
+	cols, _ := rows.Columns()
+	arrays := arrayColumns(rows)
+	for len(result.Problems) < maxVerifyRows && rows.Next() {
+		m, err := scanRow(rows, cols, arrays, false)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.Problems = append(result.Problems, m)
+	}
+	if err := rows.Err(); err != nil {
+		result.Error = fmt.Sprintf("check failed: %v", err)
+		return result
+	}
+	result.Passed = len(result.Problems) == 0
+	return result
+}
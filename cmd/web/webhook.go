@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// webhookClient is used for completion callbacks; the timeout keeps a slow
+// receiver from pinning goroutines.
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+const webhookAttempts = 3
+
+// validateCallbackURL checks that a callback URL is an absolute http(s) URL.
+func validateCallbackURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid callbackURL: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid callbackURL: must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// notifyWebhook POSTs payload as JSON to callbackURL in the background,
+// retrying with a short backoff. Failures are only logged.
+func notifyWebhook(callbackURL string, payload interface{}) {
+	if callbackURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("webhook: encoding payload: %v", err)
+		return
+	}
+
+	go func() {
+		for attempt := 1; attempt <= webhookAttempts; attempt++ {
+			resp, err := webhookClient.Post(callbackURL, "application/json", bytes.NewReader(body))
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode < 300 {
+					return
+				}
+				err = fmt.Errorf("status %s", resp.Status)
+			}
+			log.Printf("webhook: attempt %d to %s failed: %v", attempt, callbackURL, err)
+			if attempt < webhookAttempts {
+				time.Sleep(time.Duration(attempt) * time.Second)
+			}
+		}
+	}()
+}
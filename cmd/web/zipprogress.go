@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// zipProgressRetention is how long a finished export's progress can still be
+// read, so a client polling slowly sees it complete.
+const zipProgressRetention = 10 * time.Minute
+
+// jobIDPattern restricts the client-chosen export job IDs.
+var jobIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// zipProgress is how far a ZIP export has got.
+type zipProgress struct {
+	Tables     int    `json:"tables"`
+	TablesDone int    `json:"tablesDone"`
+	Table      string `json:"table,omitempty"` // the table being exported
+	Rows       int64  `json:"rows"`            // rows written so far, over all tables
+	Done       bool   `json:"done"`
+
+	updated time.Time
+}
+
+// zipProgressStore tracks running ZIP exports by the job ID the client
+// passed to /download-zip, for /download-zip/progress to report.
+type zipProgressStore struct {
+	mu   sync.Mutex
+	jobs map[string]*zipProgress
+}
+
+func newZipProgressStore() *zipProgressStore {
+	return &zipProgressStore{jobs: make(map[string]*zipProgress)}
+}
+
+// start registers job as exporting tables, dropping finished jobs whose
+// retention has passed.
+func (s *zipProgressStore) start(job string, tables int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, p := range s.jobs {
+		if p.Done && now.Sub(p.updated) > zipProgressRetention {
+			delete(s.jobs, id)
+		}
+	}
+	s.jobs[job] = &zipProgress{Tables: tables, updated: now}
+}
+
+// update applies fn to job's progress. It does nothing for an empty job, so
+// exports without a job ID needn't check.
+func (s *zipProgressStore) update(job string, fn func(p *zipProgress)) {
+	if job == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.jobs[job]; ok {
+		fn(p)
+		p.updated = time.Now()
+	}
+}
+
+func (s *zipProgressStore) get(job string) (zipProgress, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.jobs[job]
+	if !ok {
+		return zipProgress{}, false
+	}
+	return *p, true
+}
+
+// downloadZipProgress reports the progress of the ZIP export started with
+// ?jobId=job, for clients to poll while the download streams.
+func (app *Application) downloadZipProgress(w http.ResponseWriter, r *http.Request) {
+	job := r.URL.Query().Get("job")
+	if job == "" {
+		http.Error(w, "No job specified", http.StatusBadRequest)
+		return
+	}
+	progress, ok := app.ZipProgress.get(job)
+	if !ok {
+		http.Error(w, "Unknown job", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
@@ -0,0 +1,445 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the server configuration read from the environment.
+type Config struct {
+	Port        int
+	DatabaseURL string
+	// DatabaseReplicaURL is an optional read replica for queries and exports.
+	DatabaseReplicaURL string
+	// DatabaseSearchPath is set as search_path on every connection.
+	DatabaseSearchPath string
+	// DatabaseDialect is the dialect of the connected database: postgres,
+	// mysql or sqlite. It follows from the DATABASE_URL scheme; when
+	// DATABASE_DIALECT is set as well, the two must agree.
+	DatabaseDialect string
+	// SlowQueryThreshold is how long a database query may take before it is
+	// logged; zero disables the slow query log.
+	SlowQueryThreshold time.Duration
+	// TenantDatabaseURLs maps tenant IDs, sent in the X-Tenant-ID header, to
+	// their own databases.
+	TenantDatabaseURLs map[string]string
+	// HistoryDatabase is the SQLite file that keeps the query history, apart
+	// from the queried databases. Empty disables the history.
+	HistoryDatabase string
+	// LLMProvider picks the model API: gemini, or openai for any
+	// OpenAI-compatible chat completions endpoint.
+	LLMProvider   string
+	GeminiKey     string
+	GeminiModel   string
+	OpenAIKey     string
+	OpenAIBaseURL string
+	OpenAIModel   string
+	// GenerateTemperature is the data generation temperature for requests
+	// that set neither a temperature nor a preset; QueryTemperature is used
+	// for natural language queries.
+	GenerateTemperature float32
+	QueryTemperature    float32
+	// GeminiCacheTTL is how long a schema stays in Gemini's context cache
+	// for reuse by later generations; zero sends the schema every time.
+	GeminiCacheTTL time.Duration
+	// MaxRequestTokens and DailyTokenBudget cap Gemini spend per request and
+	// per UTC day; zero is unlimited.
+	MaxRequestTokens int64
+	DailyTokenBudget int64
+	// AdminToken protects the admin endpoints; they are disabled when empty.
+	AdminToken string
+	// AllowColumnTypeChanges lets /alter-schema run ALTER COLUMN ... TYPE.
+	AllowColumnTypeChanges bool
+	// FixUndefinedColumns has Gemini correct, once, a generated statement
+	// that names a column the table doesn't have.
+	FixUndefinedColumns bool
+	// QueryTables limits which tables queries may read; empty allows all.
+	QueryTables []string
+	// NamingConventions is off, warn or reject: whether /upload-ddl checks
+	// new tables and columns against the Naming*Pattern regular expressions,
+	// and whether it refuses DDL that breaks them.
+	NamingConventions       string
+	NamingTablePattern      string
+	NamingColumnPattern     string
+	NamingForeignKeyPattern string
+	// QueryMaxRows caps the rows a natural language query returns; zero
+	// leaves queries uncapped.
+	QueryMaxRows int
+	// MaxStatementBytes splits generated multi-row INSERTs so that no
+	// statement is longer than this; zero leaves them whole.
+	MaxStatementBytes int
+	// ContentBlocklist are words that safeContent generation rejects, on
+	// top of the built-in ones.
+	ContentBlocklist []string
+	// ChartKeywords are words, in any language, that mark a question as
+	// asking for a chart, on top of the built-in ones.
+	ChartKeywords []string
+	// RequestTimeout bounds every request; GenerateTimeout and QueryTimeout
+	// override it for the generation and query endpoints.
+	RequestTimeout  time.Duration
+	GenerateTimeout time.Duration
+	QueryTimeout    time.Duration
+	// LLMTimeout bounds each call to the model API within a request.
+	LLMTimeout time.Duration
+	// ListTablesPageSize is how many tables a paged /list-tables request
+	// returns when it sets no limit.
+	ListTablesPageSize int
+	// SheetsCredentialsFile is a service account key for /export/sheets,
+	// which is disabled when it is empty.
+	SheetsCredentialsFile string
+}
+
+// DefaultGeminiModel is used when GEMINI_MODEL is not set.
+const DefaultGeminiModel = "gemini-2.0-flash"
+
+// Default model temperatures. Generation wants varied data, while queries
+// should be as deterministic as possible.
+const (
+	DefaultGenerateTemperature = 0.7
+	DefaultQueryTemperature    = 0.1
+)
+
+// Defaults for the openai provider.
+const (
+	DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+	DefaultOpenAIModel   = "gpt-4o-mini"
+)
+
+// DefaultHistoryDatabase is used when HISTORY_DATABASE is not set.
+const DefaultHistoryDatabase = "query_history.db"
+
+// DefaultListTablesPageSize is used when LIST_TABLES_PAGE_SIZE is not set.
+const DefaultListTablesPageSize = 50
+
+// Default naming convention patterns: snake_case throughout, plural table
+// names and an _id suffix on foreign key columns.
+const (
+	DefaultNamingTablePattern      = `^[a-z][a-z0-9_]*s$`
+	DefaultNamingColumnPattern     = `^[a-z][a-z0-9_]*$`
+	DefaultNamingForeignKeyPattern = `^[a-z][a-z0-9_]*_id$`
+)
+
+// DefaultQueryMaxRows is used when QUERY_MAX_ROWS is not set.
+const DefaultQueryMaxRows = 1000
+
+// Default request timeouts. Generation waits on long model responses and
+// large inserts, while queries should come back quickly.
+const (
+	DefaultRequestTimeout  = 60 * time.Second
+	DefaultGenerateTimeout = 5 * time.Minute
+	DefaultQueryTimeout    = 30 * time.Second
+
+	// DefaultLLMTimeout is below DefaultQueryTimeout, so a slow model gets
+	// its own error before the whole query request times out.
+	DefaultLLMTimeout = 25 * time.Second
+)
+
+// Load reads and validates the configuration from environment variables.
+// All problems are reported together so a misconfigured deployment can be
+// fixed in one go.
+func Load() (*Config, error) {
+	cfg := &Config{
+		Port:                    4000,
+		DatabaseURL:             os.Getenv("DATABASE_URL"),
+		DatabaseReplicaURL:      os.Getenv("DATABASE_REPLICA_URL"),
+		DatabaseSearchPath:      os.Getenv("DATABASE_SEARCH_PATH"),
+		GeminiKey:               os.Getenv("GEMINI_API_KEY"),
+		GeminiModel:             os.Getenv("GEMINI_MODEL"),
+		LLMProvider:             "gemini",
+		OpenAIKey:               os.Getenv("OPENAI_API_KEY"),
+		OpenAIBaseURL:           DefaultOpenAIBaseURL,
+		OpenAIModel:             DefaultOpenAIModel,
+		GenerateTemperature:     DefaultGenerateTemperature,
+		QueryTemperature:        DefaultQueryTemperature,
+		AdminToken:              os.Getenv("ADMIN_TOKEN"),
+		SheetsCredentialsFile:   os.Getenv("GOOGLE_SHEETS_CREDENTIALS"),
+		RequestTimeout:          DefaultRequestTimeout,
+		GenerateTimeout:         DefaultGenerateTimeout,
+		QueryTimeout:            DefaultQueryTimeout,
+		LLMTimeout:              DefaultLLMTimeout,
+		HistoryDatabase:         DefaultHistoryDatabase,
+		ListTablesPageSize:      DefaultListTablesPageSize,
+		QueryMaxRows:            DefaultQueryMaxRows,
+		NamingConventions:       "off",
+		NamingTablePattern:      DefaultNamingTablePattern,
+		NamingColumnPattern:     DefaultNamingColumnPattern,
+		NamingForeignKeyPattern: DefaultNamingForeignKeyPattern,
+		FixUndefinedColumns:     true,
+	}
+
+	var errs []error
+
+	if v := os.Getenv("PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil || port < 1 || port > 65535 {
+			errs = append(errs, fmt.Errorf("PORT must be a number between 1 and 65535, got %q", v))
+		}
+		cfg.Port = port
+	}
+	if v := os.Getenv("ALLOW_COLUMN_TYPE_CHANGES"); v != "" {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ALLOW_COLUMN_TYPE_CHANGES must be a boolean, got %q", v))
+		}
+		cfg.AllowColumnTypeChanges = allow
+	}
+	if v := os.Getenv("DATABASE_DIALECT"); v != "" {
+		switch v = strings.ToLower(v); v {
+		case "postgres", "mysql", "sqlite":
+			cfg.DatabaseDialect = v
+		default:
+			errs = append(errs, fmt.Errorf("DATABASE_DIALECT must be postgres, mysql or sqlite, got %q", v))
+		}
+	}
+	if v := os.Getenv("SLOW_QUERY_THRESHOLD"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("SLOW_QUERY_THRESHOLD must be a duration such as 500ms, or 0 to disable, got %q", v))
+		}
+		cfg.SlowQueryThreshold = d
+	}
+	if v := os.Getenv("FIX_UNDEFINED_COLUMNS"); v != "" {
+		fix, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("FIX_UNDEFINED_COLUMNS must be a boolean, got %q", v))
+		}
+		cfg.FixUndefinedColumns = fix
+	}
+	if v := os.Getenv("TENANT_DATABASES"); v != "" {
+		if err := json.Unmarshal([]byte(v), &cfg.TenantDatabaseURLs); err != nil {
+			errs = append(errs, fmt.Errorf("TENANT_DATABASES must be a JSON object of tenant IDs to database URLs: %v", err))
+		}
+		for id, connStr := range cfg.TenantDatabaseURLs {
+			if id == "" || connStr == "" {
+				errs = append(errs, errors.New("TENANT_DATABASES must not contain empty tenant IDs or URLs"))
+				break
+			}
+		}
+	}
+	if v := os.Getenv("HISTORY_DATABASE"); v != "" {
+		cfg.HistoryDatabase = v
+		if strings.EqualFold(v, "off") {
+			cfg.HistoryDatabase = ""
+		}
+	}
+	if v := os.Getenv("LIST_TABLES_PAGE_SIZE"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 1 || size > 500 {
+			errs = append(errs, fmt.Errorf("LIST_TABLES_PAGE_SIZE must be a number between 1 and 500, got %q", v))
+		}
+		cfg.ListTablesPageSize = size
+	}
+	if v := os.Getenv("NAMING_CONVENTIONS"); v != "" {
+		switch v = strings.ToLower(v); v {
+		case "off", "warn", "reject":
+			cfg.NamingConventions = v
+		default:
+			errs = append(errs, fmt.Errorf("NAMING_CONVENTIONS must be off, warn or reject, got %q", v))
+		}
+	}
+	for _, t := range []struct {
+		env string
+		dst *string
+	}{
+		{"NAMING_TABLE_PATTERN", &cfg.NamingTablePattern},
+		{"NAMING_COLUMN_PATTERN", &cfg.NamingColumnPattern},
+		{"NAMING_FK_PATTERN", &cfg.NamingForeignKeyPattern},
+	} {
+		if v := os.Getenv(t.env); v != "" {
+			if _, err := regexp.Compile(v); err != nil {
+				errs = append(errs, fmt.Errorf("%s must be a regular expression: %v", t.env, err))
+			}
+			*t.dst = v
+		}
+	}
+	if v := os.Getenv("QUERY_MAX_ROWS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("QUERY_MAX_ROWS must be a non-negative number, got %q", v))
+		}
+		cfg.QueryMaxRows = n
+	}
+	if v := os.Getenv("MAX_STATEMENT_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("MAX_STATEMENT_BYTES must be a non-negative number, got %q", v))
+		}
+		cfg.MaxStatementBytes = n
+	}
+	for _, t := range []struct {
+		env string
+		dst *int64
+	}{
+		{"GEMINI_MAX_REQUEST_TOKENS", &cfg.MaxRequestTokens},
+		{"GEMINI_DAILY_TOKEN_BUDGET", &cfg.DailyTokenBudget},
+	} {
+		if v := os.Getenv(t.env); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				errs = append(errs, fmt.Errorf("%s must be a non-negative number of tokens, got %q", t.env, v))
+			}
+			*t.dst = n
+		}
+	}
+	if v := os.Getenv("QUERY_TABLE_ALLOWLIST"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cfg.QueryTables = append(cfg.QueryTables, strings.ToLower(name))
+			}
+		}
+	}
+	if v := os.Getenv("CONTENT_BLOCKLIST"); v != "" {
+		for _, word := range strings.Split(v, ",") {
+			if word = strings.TrimSpace(word); word != "" {
+				cfg.ContentBlocklist = append(cfg.ContentBlocklist, word)
+			}
+		}
+	}
+	if v := os.Getenv("CHART_KEYWORDS"); v != "" {
+		for _, kw := range strings.Split(v, ",") {
+			if kw = strings.TrimSpace(kw); kw != "" {
+				cfg.ChartKeywords = append(cfg.ChartKeywords, kw)
+			}
+		}
+	}
+	for _, t := range []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"REQUEST_TIMEOUT", &cfg.RequestTimeout},
+		{"GENERATE_TIMEOUT", &cfg.GenerateTimeout},
+		{"QUERY_TIMEOUT", &cfg.QueryTimeout},
+		{"LLM_TIMEOUT", &cfg.LLMTimeout},
+	} {
+		if v := os.Getenv(t.env); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				errs = append(errs, fmt.Errorf("%s must be a positive duration such as 30s or 5m, got %q", t.env, v))
+			}
+			*t.dst = d
+		}
+	}
+	for _, t := range []struct {
+		env string
+		dst *float32
+	}{
+		{"GEN_DEFAULT_TEMP", &cfg.GenerateTemperature},
+		{"QUERY_TEMP", &cfg.QueryTemperature},
+	} {
+		if v := os.Getenv(t.env); v != "" {
+			temp, err := strconv.ParseFloat(v, 32)
+			if err != nil || temp < 0 || temp > 2 {
+				errs = append(errs, fmt.Errorf("%s must be a number between 0 and 2, got %q", t.env, v))
+			}
+			*t.dst = float32(temp)
+		}
+	}
+	if v := os.Getenv("GEMINI_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("GEMINI_CACHE_TTL must be a duration such as 1h, or 0 to disable, got %q", v))
+		}
+		cfg.GeminiCacheTTL = d
+	}
+	if cfg.GeminiModel == "" {
+		cfg.GeminiModel = DefaultGeminiModel
+	}
+	if v := os.Getenv("LLM_PROVIDER"); v != "" {
+		switch v = strings.ToLower(v); v {
+		case "gemini", "openai":
+			cfg.LLMProvider = v
+		default:
+			errs = append(errs, fmt.Errorf("LLM_PROVIDER must be gemini or openai, got %q", v))
+		}
+	}
+	if v := os.Getenv("OPENAI_BASE_URL"); v != "" {
+		cfg.OpenAIBaseURL = v
+	}
+	if v := os.Getenv("OPENAI_MODEL"); v != "" {
+		cfg.OpenAIModel = v
+	}
+
+	if cfg.DatabaseURL == "" {
+		errs = append(errs, errors.New("DATABASE_URL is required"))
+	}
+	if cfg.LLMProvider == "gemini" && cfg.GeminiKey == "" {
+		errs = append(errs, errors.New("GEMINI_API_KEY is required"))
+	}
+	if cfg.LLMProvider == "openai" && cfg.OpenAIKey == "" {
+		errs = append(errs, errors.New("OPENAI_API_KEY is required when LLM_PROVIDER is openai"))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Public returns the configuration with secrets removed, suitable for
+// exposing through the API.
+func (c *Config) Public() map[string]interface{} {
+	return map[string]interface{}{
+		"port":                    c.Port,
+		"llmProvider":             c.LLMProvider,
+		"geminiModel":             c.GeminiModel,
+		"openaiBaseURL":           redactURL(c.OpenAIBaseURL),
+		"openaiModel":             c.OpenAIModel,
+		"generateTemperature":     c.GenerateTemperature,
+		"queryTemperature":        c.QueryTemperature,
+		"geminiCacheTTL":          c.GeminiCacheTTL.String(),
+		"maxRequestTokens":        c.MaxRequestTokens,
+		"dailyTokenBudget":        c.DailyTokenBudget,
+		"databaseURL":             redactURL(c.DatabaseURL),
+		"databaseReplicaURL":      redactURL(c.DatabaseReplicaURL),
+		"databaseSearchPath":      c.DatabaseSearchPath,
+		"databaseDialect":         c.DatabaseDialect,
+		"slowQueryThreshold":      c.SlowQueryThreshold.String(),
+		"adminEnabled":            c.AdminToken != "",
+		"allowColumnTypeChanges":  c.AllowColumnTypeChanges,
+		"fixUndefinedColumns":     c.FixUndefinedColumns,
+		"queryTables":             c.QueryTables,
+		"chartKeywords":           c.ChartKeywords,
+		"contentBlocklist":        c.ContentBlocklist,
+		"requestTimeout":          c.RequestTimeout.String(),
+		"generateTimeout":         c.GenerateTimeout.String(),
+		"queryTimeout":            c.QueryTimeout.String(),
+		"llmTimeout":              c.LLMTimeout.String(),
+		"listTablesPageSize":      c.ListTablesPageSize,
+		"queryMaxRows":            c.QueryMaxRows,
+		"maxStatementBytes":       c.MaxStatementBytes,
+		"namingConventions":       c.NamingConventions,
+		"namingTablePattern":      c.NamingTablePattern,
+		"namingColumnPattern":     c.NamingColumnPattern,
+		"namingForeignKeyPattern": c.NamingForeignKeyPattern,
+		"sheetsEnabled":           c.SheetsCredentialsFile != "",
+	}
+}
+
+// keyValuePassword matches the password of a key=value connection string,
+// quoted or not.
+var keyValuePassword = regexp.MustCompile(`(?i)\bpassword\s*=\s*('(?:[^'\\]|\\.)*'|\S*)`)
+
+// redactURL hides the password of a connection string, whether it is a URL,
+// with the password in its user info or a password parameter, or a
+// key=value string as lib/pq accepts.
+func redactURL(raw string) string {
+	if !strings.Contains(raw, "://") && strings.Contains(raw, "=") {
+		return keyValuePassword.ReplaceAllString(raw, "password=xxxxx")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "(unparseable)"
+	}
+	if q := u.Query(); q.Has("password") {
+		q.Set("password", "xxxxx")
+		u.RawQuery = q.Encode()
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,28 @@
+package config
+
+import "testing"
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"url with password", "postgres://app:secret@db:5432/genai?sslmode=disable", "postgres://app:xxxxx@db:5432/genai?sslmode=disable"},
+		{"url without password", "postgres://app@db/genai", "postgres://app@db/genai"},
+		{"url password parameter", "postgres://db/genai?password=secret&user=app", "postgres://db/genai?password=xxxxx&user=app"},
+		{"key=value", "host=db user=app password=secret dbname=genai", "host=db user=app password=xxxxx dbname=genai"},
+		{"key=value quoted with spaces", `host=db password='my s\'ecret' dbname=genai`, "host=db password=xxxxx dbname=genai"},
+		{"key=value spaced equals", "user=app password = secret", "user=app password=xxxxx"},
+		{"key=value upper case", "PASSWORD=secret host=db", "password=xxxxx host=db"},
+		{"key=value without password", "host=db user=app", "host=db user=app"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactURL(tt.raw); got != tt.want {
+				t.Errorf("redactURL(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseArray parses the text form PostgreSQL uses for array values, such as
+// {a,"b c",NULL}, into its elements. Nested arrays become nested slices, NULL
+// elements nil, and all other elements strings.
+func ParseArray(s string) ([]interface{}, error) {
+	// Arrays with non-default bounds are prefixed with their dimensions,
+	// e.g. [0:1]={a,b}
+	if strings.HasPrefix(s, "[") {
+		if i := strings.Index(s, "="); i >= 0 {
+			s = s[i+1:]
+		}
+	}
+
+	p := arrayParser{s: s}
+	elems, err := p.array()
+	if err != nil {
+		return nil, err
+	}
+	if p.i != len(s) {
+		return nil, fmt.Errorf("unexpected text after array literal %q", s)
+	}
+	return elems, nil
+}
+
+type arrayParser struct {
+	s string
+	i int
+}
+
+func (p *arrayParser) array() ([]interface{}, error) {
+	if p.i >= len(p.s) || p.s[p.i] != '{' {
+		return nil, fmt.Errorf("array literal %q must start with {", p.s)
+	}
+	p.i++
+
+	elems := []interface{}{}
+	if p.i < len(p.s) && p.s[p.i] == '}' {
+		p.i++
+		return elems, nil
+	}
+
+	for {
+		if p.i >= len(p.s) {
+			return nil, fmt.Errorf("unterminated array literal %q", p.s)
+		}
+
+		var elem interface{}
+		switch p.s[p.i] {
+		case '{':
+			nested, err := p.array()
+			if err != nil {
+				return nil, err
+			}
+			elem = nested
+		case '"':
+			var sb strings.Builder
+			p.i++
+			for p.i < len(p.s) && p.s[p.i] != '"' {
+				if p.s[p.i] == '\\' && p.i+1 < len(p.s) {
+					p.i++
+				}
+				sb.WriteByte(p.s[p.i])
+				p.i++
+			}
+			if p.i >= len(p.s) {
+				return nil, fmt.Errorf("unterminated quoted element in %q", p.s)
+			}
+			p.i++
+			elem = sb.String()
+		default:
+			start := p.i
+			for p.i < len(p.s) && p.s[p.i] != ',' && p.s[p.i] != '}' {
+				p.i++
+			}
+			text := strings.TrimSpace(p.s[start:p.i])
+			if strings.EqualFold(text, "NULL") {
+				elem = nil
+			} else {
+				elem = text
+			}
+		}
+		elems = append(elems, elem)
+
+		if p.i >= len(p.s) {
+			return nil, fmt.Errorf("unterminated array literal %q", p.s)
+		}
+		switch p.s[p.i] {
+		case ',':
+			p.i++
+		case '}':
+			p.i++
+			return elems, nil
+		default:
+			return nil, fmt.Errorf("unexpected %q in array literal %q", p.s[p.i], p.s)
+		}
+	}
+}
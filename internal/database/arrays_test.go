@@ -0,0 +1,48 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArray(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []interface{}
+	}{
+		{"tags", "{red,blue}", []interface{}{"red", "blue"}},
+		{"quoted tag", `{red,"light blue","say \"hi\""}`, []interface{}{"red", "light blue", `say "hi"`}},
+		{"null tag", "{red,NULL}", []interface{}{"red", nil}},
+		{"no tags", "{}", []interface{}{}},
+		{"nested", "{{1,2},{3,4}}", []interface{}{[]interface{}{"1", "2"}, []interface{}{"3", "4"}}},
+		{"explicit bounds", "[0:1]={a,b}", []interface{}{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseArray(tt.text)
+			if err != nil {
+				t.Fatalf("ParseArray(%q): %v", tt.text, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseArray(%q) = %#v, want %#v", tt.text, got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []string{"red,blue", "{red,blue", `{"red}`, "{a}b"} {
+		if _, err := ParseArray(bad); err == nil {
+			t.Errorf("ParseArray(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestFormatSchemaArrayColumn(t *testing.T) {
+	tables := []Table{{Name: "posts", Columns: []Column{
+		{Name: "id", DataType: "integer"},
+		{Name: "tags", DataType: "text[]"},
+	}}}
+	if got, want := FormatSchema(tables, nil), "posts(id integer, tags text[])\n"; got != want {
+		t.Errorf("FormatSchema = %q, want %q", got, want)
+	}
+}
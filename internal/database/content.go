@@ -0,0 +1,101 @@
+package database
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DefaultBlockedWords are the words safe-content generation keeps out of
+// text values. CONTENT_BLOCKLIST adds to them, e.g. with names or terms
+// specific to a customer.
+var DefaultBlockedWords = []string{
+	"asshole", "bastard", "bitch", "bullshit", "crap", "cunt", "dick", "fuck",
+	"fucking", "motherfucker", "piss", "shit", "slut", "whore",
+	"cabrón", "coño", "gilipollas", "joder", "mierda", "pendejo", "puta",
+}
+
+// ContentFilter finds text values containing blocked words. Words match
+// whole and regardless of case, so "Scrapbook" doesn't match "crap"; entries
+// of several words match as a phrase.
+type ContentFilter struct {
+	words []string // normalized, each surrounded by spaces
+}
+
+func NewContentFilter(words []string) *ContentFilter {
+	f := &ContentFilter{}
+	for _, w := range words {
+		if w = normalizeWords(w); w != "" {
+			f.words = append(f.words, " "+w+" ")
+		}
+	}
+	return f
+}
+
+// Blocked reports whether value contains a blocked word.
+func (f *ContentFilter) Blocked(value string) bool {
+	padded := " " + normalizeWords(value) + " "
+	for _, w := range f.words {
+		if strings.Contains(padded, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterRows returns stmt without the VALUES rows that hold a blocked word,
+// together with the offending values. A statement that isn't a plain INSERT
+// is dropped whole, returning "", when any of its literals is blocked.
+func (f *ContentFilter) FilterRows(stmt string) (string, []string) {
+	ins, err := ParseInsert(stmt)
+	if err != nil {
+		blocked := f.blockedLiterals(stmt)
+		if len(blocked) > 0 {
+			return "", blocked
+		}
+		return stmt, nil
+	}
+
+	var flagged []string
+	kept := ins.Rows[:0]
+	for _, row := range ins.Rows {
+		var rowFlagged []string
+		for _, value := range row {
+			rowFlagged = append(rowFlagged, f.blockedLiterals(value)...)
+		}
+		if len(rowFlagged) == 0 {
+			kept = append(kept, row)
+		}
+		flagged = append(flagged, rowFlagged...)
+	}
+	if len(flagged) == 0 {
+		return stmt, nil
+	}
+	if len(kept) == 0 {
+		return "", flagged
+	}
+	ins.Rows = kept
+	return ins.String(), flagged
+}
+
+// blockedLiterals returns the string literals in sql that are blocked.
+func (f *ContentFilter) blockedLiterals(sql string) []string {
+	var blocked []string
+	for _, tok := range tokenize(sql) {
+		if tok.kind != tokenString {
+			continue
+		}
+		if value := strings.ReplaceAll(tok.text, "''", "'"); f.Blocked(value) {
+			blocked = append(blocked, value)
+		}
+	}
+	return blocked
+}
+
+// normalizeWords lowercases s and reduces it to its words separated by
+// single spaces.
+func normalizeWords(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsQuerySafe(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		safe   bool
+		reason string
+	}{
+		{"plain select", "SELECT * FROM users", true, ""},
+		{"trailing semicolon", "SELECT * FROM users;", true, ""},
+		{"keyword inside column name", "SELECT updated_at FROM users ORDER BY updated_at", true, ""},
+		{"keyword inside string literal", "SELECT * FROM notes WHERE body = 'please update me'", true, ""},
+		{"keyword inside quoted identifier", `SELECT "delete" FROM flags`, true, ""},
+		{"keyword inside comment", "SELECT 1 -- DROP TABLE users", true, ""},
+		{"plain delete", "DELETE FROM users", false, "forbidden keyword DELETE"},
+		{"lower case drop", "drop table users", false, "forbidden keyword DROP"},
+		{"second statement", "SELECT 1; SELECT 2", false, "more than one statement"},
+		{"escape string hiding a second statement", `SELECT E'\''; DELETE FROM users; --'`, false, "more than one statement"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			safe, reason := IsQuerySafe(tt.query)
+			if safe != tt.safe || reason != tt.reason {
+				t.Errorf("IsQuerySafe(%q) = %v, %q; want %v, %q", tt.query, safe, reason, tt.safe, tt.reason)
+			}
+		})
+	}
+}
+
+func TestIsQuerySafeMySQLEscapes(t *testing.T) {
+	defer SetDialect(ActiveDialect())
+	SetDialect(MySQL)
+
+	// In MySQL \' doesn't end the string, so the second quote does and the
+	// DELETE runs as a statement of its own
+	if safe, _ := IsQuerySafe(`SELECT '\'' ; DELETE FROM users; -- '`); safe {
+		t.Error("a statement after a backslash-escaped quote was accepted")
+	}
+	if safe, _ := IsQuerySafe("SELECT 1 # DROP TABLE users"); !safe {
+		t.Error("a keyword in a # comment was rejected")
+	}
+}
+
+func TestDialectOf(t *testing.T) {
+	tests := []struct {
+		connStr string
+		want    Dialect
+	}{
+		{"postgres://u:p@db:5432/app?sslmode=disable", Postgres},
+		{"postgresql://db/app", Postgres},
+		{"host=db user=app password=secret dbname=app", Postgres},
+		{"mysql://u:p@db:3306/app", MySQL},
+		{"sqlite:data/app.db", SQLite},
+		{"sqlite3:///tmp/app.db", SQLite},
+		{"file:app.db?cache=shared", SQLite},
+	}
+	for _, tt := range tests {
+		got, err := DialectOf(tt.connStr)
+		if err != nil || got != tt.want {
+			t.Errorf("DialectOf(%q) = %s, %v; want %s", tt.connStr, got, err, tt.want)
+		}
+	}
+	if _, err := DialectOf("sqlserver://db/app"); err == nil {
+		t.Error("DialectOf accepted an unsupported scheme")
+	}
+}
+
+func TestMySQLDSN(t *testing.T) {
+	got, err := mysqlDSN("mysql://app:p%40ss@db/shop?tls=skip-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{"app:p@ss@tcp(db:3306)/shop", "parseTime=true", "tls=skip-verify"} {
+		if !strings.Contains(got, part) {
+			t.Errorf("mysqlDSN = %s, missing %s", got, part)
+		}
+	}
+	if _, err := mysqlDSN("mysql://app@db:3306"); err == nil {
+		t.Error("mysqlDSN accepted a URL without a database")
+	}
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	const pragmas = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	tests := []struct {
+		connStr string
+		want    string
+	}{
+		{"sqlite:data/app.db", "file:data/app.db?" + pragmas},
+		{"sqlite:///tmp/app.db", "file:/tmp/app.db?" + pragmas},
+		{"sqlite3://app.db", "file:app.db?" + pragmas},
+		{"file:app.db?mode=ro", "file:app.db?mode=ro&" + pragmas},
+	}
+	for _, tt := range tests {
+		if got := sqliteDSN(tt.connStr); got != tt.want {
+			t.Errorf("sqliteDSN(%q) = %s, want %s", tt.connStr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateAdditiveDDL checks that every statement in ddl is an ALTER TABLE
+// that only adds columns. When allowTypeChanges is set, ALTER COLUMN ... TYPE
+// is accepted too. It returns the individual statements on success.
+func ValidateAdditiveDDL(ddl string, allowTypeChanges bool) ([]string, error) {
+	var statements []string
+	for _, stmt := range splitOutside(ddl, ';') {
+		stmt = strings.TrimSpace(stmt)
+		if stmt == "" {
+			continue
+		}
+		if err := validateAlterTable(stmt, allowTypeChanges); err != nil {
+			return nil, err
+		}
+		statements = append(statements, stmt)
+	}
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("no statements found")
+	}
+	return statements, nil
+}
+
+func validateAlterTable(stmt string, allowTypeChanges bool) error {
+	rest, ok := cutPrefixFold(stmt, "ALTER")
+	if ok {
+		rest, ok = cutPrefixFold(strings.TrimSpace(rest), "TABLE")
+	}
+	if !ok {
+		return fmt.Errorf("only ALTER TABLE statements are allowed: %s", stmt)
+	}
+	rest = strings.TrimSpace(rest)
+	if r, ok := cutPrefixFold(rest, "IF EXISTS"); ok {
+		rest = strings.TrimSpace(r)
+	}
+	if r, ok := cutPrefixFold(rest, "ONLY "); ok {
+		rest = strings.TrimSpace(r)
+	}
+
+	table, rest := readIdentifier(rest)
+	if table == "" {
+		return fmt.Errorf("missing table name: %s", stmt)
+	}
+
+	for _, action := range splitTopLevel(rest) {
+		words := strings.Fields(strings.ToUpper(action))
+		if len(words) < 2 {
+			return fmt.Errorf("incomplete action %q on %s", strings.TrimSpace(action), table)
+		}
+
+		switch words[0] {
+		case "ADD":
+			if !addsColumn(action) {
+				return fmt.Errorf("only column additions are allowed, got %q on %s", strings.TrimSpace(action), table)
+			}
+		case "ALTER":
+			if !allowTypeChanges || !isTypeChange(words) {
+				return fmt.Errorf("column changes are not allowed, got %q on %s", strings.TrimSpace(action), table)
+			}
+		default:
+			return fmt.Errorf("only ADD COLUMN is allowed, got %q on %s", strings.TrimSpace(action), table)
+		}
+	}
+	return nil
+}
+
+// notColumnNames are the words that, right after ADD, start an index, key,
+// constraint or partition instead of naming a new column.
+var notColumnNames = map[string]bool{
+	"CONSTRAINT": true, "PRIMARY": true, "UNIQUE": true, "FOREIGN": true,
+	"CHECK": true, "EXCLUDE": true, "INDEX": true, "KEY": true,
+	"FULLTEXT": true, "SPATIAL": true, "PARTITION": true, "PERIOD": true,
+}
+
+// addsColumn reports whether an ADD action is
+// ADD [COLUMN] [IF NOT EXISTS] name type ...
+func addsColumn(action string) bool {
+	rest, _ := cutWordFold(strings.TrimSpace(action), "ADD")
+	if r, ok := cutWordFold(rest, "COLUMN"); ok {
+		rest = r
+	}
+	if r, ok := cutWordFold(rest, "IF NOT EXISTS"); ok {
+		rest = r
+	}
+
+	name, rest := readIdentifier(rest)
+	if name == "" || name[0] != '"' && name[0] != '`' && notColumnNames[strings.ToUpper(name)] {
+		return false
+	}
+	typ := strings.TrimSpace(rest)
+	return typ != "" && isWordStart(typ[0])
+}
+
+// cutWordFold is cutPrefixFold for a prefix that must be followed by
+// whitespace, so ADD doesn't match ADDRESS. The rest is returned trimmed.
+func cutWordFold(s, prefix string) (string, bool) {
+	rest, ok := cutPrefixFold(s, prefix)
+	if !ok || rest == "" || !strings.ContainsRune(" \t\r\n", rune(rest[0])) {
+		return s, false
+	}
+	return strings.TrimSpace(rest), true
+}
+
+// isTypeChange reports whether an uppercased ALTER action is
+// ALTER [COLUMN] name [SET DATA] TYPE ...
+func isTypeChange(words []string) bool {
+	i := 1
+	if words[i] == "COLUMN" {
+		i++
+	}
+	i++ // column name
+	if i+1 < len(words) && words[i] == "SET" && words[i+1] == "DATA" {
+		i += 2
+	}
+	return i < len(words) && words[i] == "TYPE"
+}
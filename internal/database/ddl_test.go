@@ -0,0 +1,59 @@
+package database
+
+import "testing"
+
+func TestValidateAdditiveDDL(t *testing.T) {
+	accepted := []string{
+		"ALTER TABLE users ADD COLUMN age integer",
+		"ALTER TABLE users ADD age integer",
+		"alter table users add column if not exists age int",
+		`ALTER TABLE users ADD COLUMN "key" text`,
+		"ALTER TABLE `users` ADD `index` varchar(20) NOT NULL DEFAULT ''",
+		"ALTER TABLE users ADD COLUMN a int, ADD COLUMN b numeric(10, 2);",
+		"ALTER TABLE IF EXISTS ONLY users ADD COLUMN nickname text",
+		"ALTER TABLE users ADD COLUMN keywords text[]",
+	}
+	for _, ddl := range accepted {
+		if _, err := ValidateAdditiveDDL(ddl, false); err != nil {
+			t.Errorf("ValidateAdditiveDDL(%q): %v", ddl, err)
+		}
+	}
+
+	rejected := []string{
+		"ALTER TABLE users ADD INDEX idx_age (age)",
+		"ALTER TABLE users ADD KEY idx_age (age)",
+		"ALTER TABLE users ADD UNIQUE KEY uq_email (email)",
+		"ALTER TABLE users ADD FULLTEXT ft_bio (bio)",
+		"ALTER TABLE users ADD SPATIAL INDEX sp_loc (loc)",
+		"ALTER TABLE users ADD PARTITION (PARTITION p1 VALUES LESS THAN (2000))",
+		"ALTER TABLE users ADD CONSTRAINT age_positive CHECK (age > 0)",
+		"ALTER TABLE users ADD PRIMARY KEY (id)",
+		"ALTER TABLE users ADD FOREIGN KEY (org_id) REFERENCES orgs (id)",
+		"ALTER TABLE users ADD COLUMN age",
+		"ALTER TABLE users ADD (age int)",
+		"ALTER TABLE users DROP COLUMN age",
+		"ALTER TABLE users ALTER COLUMN age TYPE bigint",
+		"ALTER TABLE users ADD COLUMN age int, DROP COLUMN name",
+		"DROP TABLE users",
+		"",
+	}
+	for _, ddl := range rejected {
+		if _, err := ValidateAdditiveDDL(ddl, false); err == nil {
+			t.Errorf("ValidateAdditiveDDL(%q) accepted it", ddl)
+		}
+	}
+}
+
+func TestValidateAdditiveDDLTypeChanges(t *testing.T) {
+	for _, ddl := range []string{
+		"ALTER TABLE users ALTER COLUMN age TYPE bigint",
+		"ALTER TABLE users ALTER age SET DATA TYPE bigint",
+	} {
+		if _, err := ValidateAdditiveDDL(ddl, true); err != nil {
+			t.Errorf("ValidateAdditiveDDL(%q, true): %v", ddl, err)
+		}
+	}
+	if _, err := ValidateAdditiveDDL("ALTER TABLE users ALTER COLUMN age DROP NOT NULL", true); err == nil {
+		t.Error("a column change other than its type was accepted")
+	}
+}
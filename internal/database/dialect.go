@@ -0,0 +1,104 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect is a SQL dialect. It decides how identifiers are quoted in the SQL
+// this package builds and in generated statements it rewrites.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+)
+
+// activeDialect is the dialect of the configured database; set it with
+// SetDialect before serving requests.
+var activeDialect = Postgres
+
+// ParseDialect returns the dialect named name.
+func ParseDialect(name string) (Dialect, error) {
+	switch d := Dialect(strings.ToLower(name)); d {
+	case Postgres, MySQL, SQLite:
+		return d, nil
+	}
+	return "", fmt.Errorf("unknown SQL dialect %q, expected postgres, mysql or sqlite", name)
+}
+
+// SetDialect sets the dialect used for quoting. InitDB sets it from the
+// connection string.
+func SetDialect(d Dialect) {
+	activeDialect = d
+}
+
+// ActiveDialect returns the dialect set with SetDialect.
+func ActiveDialect() Dialect {
+	return activeDialect
+}
+
+// identifierQuote is the character that quotes identifiers: backticks in
+// MySQL and double quotes, as in standard SQL, in PostgreSQL and SQLite.
+func (d Dialect) identifierQuote() string {
+	if d == MySQL {
+		return "`"
+	}
+	return `"`
+}
+
+// QuoteIdentifier quotes a table or column name in the dialect, doubling any
+// quote characters inside it.
+func (d Dialect) QuoteIdentifier(name string) string {
+	q := d.identifierQuote()
+	return q + strings.ReplaceAll(name, q, q+q) + q
+}
+
+// Name returns the dialect's display name, e.g. PostgreSQL.
+func (d Dialect) Name() string {
+	switch d {
+	case MySQL:
+		return "MySQL"
+	case SQLite:
+		return "SQLite"
+	}
+	return "PostgreSQL"
+}
+
+// Placeholder returns the placeholder for the nth (1-based) query
+// parameter: $n in PostgreSQL, ? in MySQL and SQLite.
+func (d Dialect) Placeholder(n int) string {
+	if d == Postgres {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// Text casts a SQL expression to text.
+func (d Dialect) Text(expr string) string {
+	switch d {
+	case MySQL:
+		return "CAST(" + expr + " AS CHAR)"
+	case SQLite:
+		return "CAST(" + expr + " AS TEXT)"
+	}
+	return expr + "::text"
+}
+
+// Random is the function that orders rows randomly.
+func (d Dialect) Random() string {
+	if d == MySQL {
+		return "RAND()"
+	}
+	return "random()"
+}
+
+// QuoteLiteral quotes a string as a SQL string literal in the dialect.
+// MySQL treats backslashes in literals as escapes, so they are doubled.
+func (d Dialect) QuoteLiteral(value string) string {
+	if d == MySQL {
+		value = strings.ReplaceAll(value, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,50 @@
+package database
+
+import "testing"
+
+func TestDialectQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		name    string
+		want    string
+	}{
+		{Postgres, "users", `"users"`},
+		{Postgres, `odd"name`, `"odd""name"`},
+		{Postgres, "has`tick", "\"has`tick\""},
+		{MySQL, "users", "`users`"},
+		{MySQL, "odd`name", "`odd``name`"},
+		{MySQL, `has"quote`, "`has\"quote`"},
+		{SQLite, "users", `"users"`},
+		{SQLite, `odd"name`, `"odd""name"`},
+	}
+	for _, tt := range tests {
+		if got := tt.dialect.QuoteIdentifier(tt.name); got != tt.want {
+			t.Errorf("%s.QuoteIdentifier(%q) = %s, want %s", tt.dialect, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteIdentifierFollowsActiveDialect(t *testing.T) {
+	defer SetDialect(ActiveDialect())
+
+	SetDialect(MySQL)
+	if got := QuoteIdentifier("order"); got != "`order`" {
+		t.Errorf("with MySQL active, QuoteIdentifier(order) = %s, want `order`", got)
+	}
+	SetDialect(Postgres)
+	if got := QuoteIdentifier("order"); got != `"order"` {
+		t.Errorf(`with Postgres active, QuoteIdentifier(order) = %s, want "order"`, got)
+	}
+}
+
+func TestParseDialect(t *testing.T) {
+	for name, want := range map[string]Dialect{"postgres": Postgres, "MySQL": MySQL, "sqlite": SQLite} {
+		got, err := ParseDialect(name)
+		if err != nil || got != want {
+			t.Errorf("ParseDialect(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseDialect("oracle"); err == nil {
+		t.Error("ParseDialect(oracle) succeeded, want an error")
+	}
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// undefinedColumn is the SQLSTATE Postgres reports for a reference to a
+// column that doesn't exist.
+const undefinedColumn = "42703"
+
+// mysqlBadField is MySQL's ER_BAD_FIELD_ERROR, its unknown column error.
+const mysqlBadField = 1054
+
+// IsUndefinedColumn reports whether err is the database rejecting a
+// statement for naming a column the table doesn't have. Generated SQL fails
+// this way when the model invents a column, which is worth asking it to
+// correct; other errors are not.
+func IsUndefinedColumn(err error) bool {
+	var pqErr *pq.Error
+	var mysqlErr *mysql.MySQLError
+	switch {
+	case err == nil:
+		return false
+	case errors.As(err, &pqErr):
+		return pqErr.Code == undefinedColumn
+	case errors.As(err, &mysqlErr):
+		return mysqlErr.Number == mysqlBadField
+	case ActiveDialect() == SQLite:
+		// SQLite reports a generic error code, so only the message tells
+		msg := err.Error()
+		return strings.Contains(msg, "no such column") || strings.Contains(msg, "has no column named")
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// filterOperators are the comparisons a Filter accepts, two-character ones
+// first so that >= isn't read as >.
+var filterOperators = []string{">=", "<=", "!=", "<>", "=", ">", "<"}
+
+// Filter is a single column comparison parsed from user input, such as
+// status=active or price>=10. The value is always bound as a query
+// parameter, never interpolated.
+type Filter struct {
+	Column string
+	Op     string
+	Value  string
+}
+
+// ParseFilter parses a column, a comparison operator and a value. The
+// column must be a plain identifier; anything else, including several
+// conditions or function calls, is rejected.
+func ParseFilter(expr string) (Filter, error) {
+	at, op := -1, ""
+	for _, candidate := range filterOperators {
+		if i := strings.Index(expr, candidate); i >= 0 && (at < 0 || i < at) {
+			at, op = i, candidate
+		}
+	}
+	if at < 0 {
+		return Filter{}, fmt.Errorf("invalid filter %q, expected column=value", expr)
+	}
+
+	column := strings.TrimSpace(expr[:at])
+	if !isPlainIdentifier(column) {
+		return Filter{}, fmt.Errorf("invalid filter %q, the column must be a plain column name", expr)
+	}
+	return Filter{Column: column, Op: op, Value: expr[at+len(op):]}, nil
+}
+
+// SQL renders the filter as a condition using the active dialect's
+// placeholder for parameter arg, e.g. $2 or ?, as the value, after checking
+// the column is one of columns.
+func (f Filter) SQL(columns []string, arg int) (string, error) {
+	if !slices.Contains(columns, f.Column) {
+		return "", fmt.Errorf("unknown column %s", f.Column)
+	}
+	return fmt.Sprintf("%s %s %s", QuoteIdentifier(f.Column), f.Op, ActiveDialect().Placeholder(arg)), nil
+}
+
+func isPlainIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,318 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ForeignKey is a single-column foreign key reference.
+type ForeignKey struct {
+	Table     string `json:"table"`
+	Column    string `json:"column"`
+	RefTable  string `json:"refTable"`
+	RefColumn string `json:"refColumn"`
+}
+
+// GetForeignKeys returns the foreign keys defined in the current schema
+func GetForeignKeys(ctx context.Context) ([]ForeignKey, error) {
+	query := `
+		SELECT tc.table_name, kcu.column_name, ccu.table_name, ccu.column_name
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema
+		JOIN information_schema.constraint_column_usage ccu
+			ON tc.constraint_name = ccu.constraint_name AND tc.table_schema = ccu.table_schema
+		WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_schema = current_schema()
+		ORDER BY tc.table_name, kcu.ordinal_position;
+	`
+	switch ActiveDialect() {
+	case MySQL:
+		query = `
+			SELECT table_name, column_name, referenced_table_name, referenced_column_name
+			FROM information_schema.key_column_usage
+			WHERE table_schema = DATABASE() AND referenced_table_name IS NOT NULL
+			ORDER BY table_name, ordinal_position;
+		`
+	case SQLite:
+		// A reference without a column is to the parent's primary key.
+		// Keys are listed in the order of their columns.
+		query = `
+			SELECT m.name, f."from", f."table",
+				COALESCE(f."to", (SELECT p.name FROM pragma_table_info(f."table") p WHERE p.pk = 1))
+			FROM sqlite_master m
+			JOIN pragma_foreign_key_list(m.name) f
+			WHERE m.type = 'table'
+			ORDER BY m.name, (SELECT p.cid FROM pragma_table_info(m.name) p WHERE p.name = f."from");
+		`
+	}
+	rows, err := QueryLogged(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fks []ForeignKey
+	for rows.Next() {
+		var fk ForeignKey
+		if err := rows.Scan(&fk.Table, &fk.Column, &fk.RefTable, &fk.RefColumn); err != nil {
+			return nil, err
+		}
+		fks = append(fks, fk)
+	}
+	return fks, rows.Err()
+}
+
+// SampleValues returns up to limit distinct non-NULL values of a column,
+// picked at random and rendered as text, e.g. existing parent keys that a
+// child row may reference.
+func SampleValues(ctx context.Context, table, column string, limit int) ([]string, error) {
+	return queryValues(ctx, randomValuesQuery(table, column), limit)
+}
+
+// LookupValues returns every distinct non-NULL value of a column when there
+// are at most max of them, as for the keys of a small lookup table. ok is
+// false when the column holds more values than that.
+func LookupValues(ctx context.Context, table, column string, max int) (values []string, ok bool, err error) {
+	d := ActiveDialect()
+	query := fmt.Sprintf(
+		"SELECT DISTINCT %s FROM %s WHERE %s IS NOT NULL ORDER BY 1 LIMIT %s",
+		d.Text(QuoteIdentifier(column)), QuoteIdentifier(table), QuoteIdentifier(column), d.Placeholder(1))
+	values, err = queryValues(ctx, query, max+1)
+	if err != nil || len(values) > max {
+		return nil, false, err
+	}
+	return values, true, nil
+}
+
+// randomValuesQuery selects distinct non-NULL values of a column as text,
+// in random order, up to the limit given as its only parameter.
+func randomValuesQuery(table, column string) string {
+	d := ActiveDialect()
+	return fmt.Sprintf(
+		"SELECT v FROM (SELECT DISTINCT %s AS v FROM %s WHERE %s IS NOT NULL) s ORDER BY %s LIMIT %s",
+		d.Text(QuoteIdentifier(column)), QuoteIdentifier(table), QuoteIdentifier(column), d.Random(), d.Placeholder(1))
+}
+
+func queryValues(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := QueryLogged(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// OrderByDependencies returns tables in the order of SortByDependencies,
+// parents before the tables that reference them, so a prompt listing them
+// leads the model to insert referenced rows first.
+func OrderByDependencies(tables []Table, fks []ForeignKey) []Table {
+	names := make([]string, len(tables))
+	byName := make(map[string]Table, len(tables))
+	for i, t := range tables {
+		names[i] = t.Name
+		byName[t.Name] = t
+	}
+	sorted, _ := SortByDependencies(names, fks)
+
+	ordered := make([]Table, len(sorted))
+	for i, name := range sorted {
+		ordered[i] = byName[name]
+	}
+	return ordered
+}
+
+// SortByDependencies orders tables so that every table comes after the
+// tables it references. Tables that take part in a circular dependency can't
+// be ordered; they are appended at the end and each cycle is returned
+// separately. Self-references are ignored.
+func SortByDependencies(tables []string, fks []ForeignKey) ([]string, [][]string) {
+	deps := make(map[string]map[string]bool, len(tables))
+	for _, t := range tables {
+		deps[t] = make(map[string]bool)
+	}
+	for _, fk := range fks {
+		if fk.Table == fk.RefTable {
+			continue
+		}
+		if _, ok := deps[fk.Table]; !ok {
+			continue
+		}
+		if _, ok := deps[fk.RefTable]; !ok {
+			continue
+		}
+		deps[fk.Table][fk.RefTable] = true
+	}
+
+	// Kahn's algorithm, picking alphabetically among ready tables so the
+	// result is deterministic.
+	remaining := make(map[string]int, len(tables))
+	dependents := make(map[string][]string)
+	for t, refs := range deps {
+		remaining[t] = len(refs)
+		for ref := range refs {
+			dependents[ref] = append(dependents[ref], t)
+		}
+	}
+
+	var ready []string
+	for t, n := range remaining {
+		if n == 0 {
+			ready = append(ready, t)
+		}
+	}
+
+	var ordered []string
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		t := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, t)
+		delete(remaining, t)
+		for _, d := range dependents[t] {
+			remaining[d]--
+			if remaining[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+
+	if len(remaining) == 0 {
+		return ordered, nil
+	}
+
+	var stuck []string
+	for t := range remaining {
+		stuck = append(stuck, t)
+	}
+	sort.Strings(stuck)
+	return append(ordered, stuck...), findCycles(stuck, deps)
+}
+
+// findCycles returns the strongly connected components with more than one
+// table among nodes (Tarjan's algorithm).
+func findCycles(nodes []string, deps map[string]map[string]bool) [][]string {
+	index := 0
+	indices := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var cycles [][]string
+
+	var visit func(string)
+	visit = func(v string) {
+		indices[v] = index
+		lowlink[v] = index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		refs := make([]string, 0, len(deps[v]))
+		for w := range deps[v] {
+			refs = append(refs, w)
+		}
+		sort.Strings(refs)
+		for _, w := range refs {
+			if _, seen := indices[w]; !seen {
+				visit(w)
+				lowlink[v] = min(lowlink[v], lowlink[w])
+			} else if onStack[w] {
+				lowlink[v] = min(lowlink[v], indices[w])
+			}
+		}
+
+		if lowlink[v] == indices[v] {
+			var component []string
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				component = append(component, w)
+				if w == v {
+					break
+				}
+			}
+			if len(component) > 1 {
+				sort.Strings(component)
+				cycles = append(cycles, component)
+			}
+		}
+	}
+
+	for _, n := range nodes {
+		if _, seen := indices[n]; !seen {
+			visit(n)
+		}
+	}
+	return cycles
+}
+
+// DescribeCycles renders circular dependencies for error messages.
+func DescribeCycles(cycles [][]string) string {
+	parts := make([]string, len(cycles))
+	for i, c := range cycles {
+		parts[i] = "circular dependency between " + strings.Join(c, ", ")
+	}
+	advice := "declare the foreign keys DEFERRABLE so they can be checked at commit"
+	if ActiveDialect() == MySQL {
+		advice = "MySQL checks foreign keys on every row, so make one of them nullable"
+	}
+	return fmt.Sprintf("%s; %s", strings.Join(parts, "; "), advice)
+}
+
+// DeferConstraints postpones foreign key checks in tx to its commit, so
+// tables that reference each other can be filled in any order. PostgreSQL
+// only defers constraints declared DEFERRABLE, and MySQL can't defer them at
+// all, so there it does nothing.
+func DeferConstraints(ctx context.Context, tx *sql.Tx) error {
+	var err error
+	switch ActiveDialect() {
+	case Postgres:
+		_, err = tx.ExecContext(ctx, "SET CONSTRAINTS ALL DEFERRED")
+	case SQLite:
+		// Reset by SQLite when the transaction ends
+		_, err = tx.ExecContext(ctx, "PRAGMA defer_foreign_keys = ON")
+	}
+	return err
+}
+
+// FindIsolatedTables returns the tables of the current schema that neither
+// reference another table nor are referenced by one, sorted by name. A
+// table whose only foreign key references itself counts as isolated.
+func FindIsolatedTables(ctx context.Context) ([]string, error) {
+	tables, err := GetTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fks, err := GetForeignKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	linked := make(map[string]bool)
+	for _, fk := range fks {
+		if fk.Table != fk.RefTable {
+			linked[fk.Table] = true
+			linked[fk.RefTable] = true
+		}
+	}
+
+	isolated := []string{}
+	for _, t := range tables {
+		if !linked[t] {
+			isolated = append(isolated, t)
+		}
+	}
+	return isolated, nil
+}
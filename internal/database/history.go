@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// The natural language queries that ran successfully are kept in a SQLite
+// database of the server's own, not in the database being queried: that may
+// be read-only, and a table added to it would show up among the user's own.
+// Each tenant's queries are kept apart by a tenant column.
+var historyDB *sql.DB
+
+// ErrHistoryDisabled is returned when no history database is open.
+var ErrHistoryDisabled = errors.New("query history is disabled")
+
+const createHistoryTable = `
+	CREATE TABLE IF NOT EXISTS query_history (
+		id integer PRIMARY KEY AUTOINCREMENT,
+		tenant text NOT NULL DEFAULT '',
+		prompt text NOT NULL,
+		sql text NOT NULL,
+		is_chart boolean NOT NULL DEFAULT false,
+		chart_type text NOT NULL DEFAULT '',
+		created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS query_history_tenant ON query_history (tenant, id);
+`
+
+// historyColumns lists the columns a HistoryEntry is scanned from.
+const historyColumns = "id, prompt, sql, is_chart, chart_type, created_at"
+
+// HistoryEntry is one recorded natural language query.
+type HistoryEntry struct {
+	ID        int64     `json:"id"`
+	Prompt    string    `json:"prompt"`
+	SQL       string    `json:"sql"`
+	IsChart   bool      `json:"isChart"`
+	ChartType string    `json:"chartType,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OpenHistory opens, creating it if needed, the SQLite file that keeps the
+// query history, and closes any history database opened before.
+func OpenHistory(path string) error {
+	db, err := sql.Open("sqlite", sqliteDSN(path))
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(createHistoryTable); err != nil {
+		db.Close()
+		return err
+	}
+	CloseHistory()
+	historyDB = db
+	return nil
+}
+
+// CloseHistory closes the history database, disabling the history.
+func CloseHistory() {
+	if historyDB != nil {
+		historyDB.Close()
+		historyDB = nil
+	}
+}
+
+// RecordQuery adds a query to the history of the context's tenant. Without a
+// history database it does nothing.
+func RecordQuery(ctx context.Context, prompt, sqlText string, isChart bool, chartType string) error {
+	if historyDB == nil {
+		return nil
+	}
+	_, err := historyDB.ExecContext(ctx, "INSERT INTO query_history (tenant, prompt, sql, is_chart, chart_type) VALUES (?, ?, ?, ?, ?)",
+		TenantFromContext(ctx), prompt, sqlText, isChart, chartType)
+	return err
+}
+
+// QueryHistory returns the context's tenant's last limit recorded queries,
+// newest first.
+func QueryHistory(ctx context.Context, limit int) ([]HistoryEntry, error) {
+	if historyDB == nil {
+		return nil, ErrHistoryDisabled
+	}
+	rows, err := historyDB.QueryContext(ctx, "SELECT "+historyColumns+" FROM query_history WHERE tenant = ? ORDER BY id DESC LIMIT ?",
+		TenantFromContext(ctx), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []HistoryEntry{}
+	for rows.Next() {
+		var e HistoryEntry
+		if err := rows.Scan(&e.ID, &e.Prompt, &e.SQL, &e.IsChart, &e.ChartType, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// GetHistoryEntry returns the context's tenant's recorded query with the
+// given id, or nil if there is none.
+func GetHistoryEntry(ctx context.Context, id int64) (*HistoryEntry, error) {
+	if historyDB == nil {
+		return nil, ErrHistoryDisabled
+	}
+	var e HistoryEntry
+	err := historyDB.QueryRowContext(ctx, "SELECT "+historyColumns+" FROM query_history WHERE tenant = ? AND id = ?", TenantFromContext(ctx), id).
+		Scan(&e.ID, &e.Prompt, &e.SQL, &e.IsChart, &e.ChartType, &e.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
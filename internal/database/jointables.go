@@ -0,0 +1,221 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// maxJoinParentKeys caps how many keys of each parent are read when pairing
+// the rows of a join table.
+const maxJoinParentKeys = 1000
+
+// JoinTable is a pure many-to-many join table, such as
+// user_roles(user_id, role_id): two foreign keys that together make up its
+// primary key, and no other column that needs a value.
+type JoinTable struct {
+	Table string     `json:"table"`
+	Left  ForeignKey `json:"left"`
+	Right ForeignKey `json:"right"`
+}
+
+// FindJoinTables returns the pure join tables of the current schema, sorted
+// by name. Other columns are allowed as long as they are nullable or have a
+// default, like a created_at timestamp.
+func FindJoinTables(ctx context.Context) ([]JoinTable, error) {
+	fks, err := GetForeignKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byTable := make(map[string][]ForeignKey)
+	for _, fk := range fks {
+		if fk.Table != fk.RefTable {
+			byTable[fk.Table] = append(byTable[fk.Table], fk)
+		}
+	}
+
+	pkQuery := `
+		SELECT tc.table_name, kcu.column_name
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON kcu.constraint_schema = tc.constraint_schema AND kcu.constraint_name = tc.constraint_name
+		WHERE tc.table_schema = current_schema() AND tc.constraint_type = 'PRIMARY KEY';
+	`
+	// Columns an INSERT must give a value for
+	requiredQuery := `
+		SELECT table_name, column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND is_nullable = 'NO'
+			AND column_default IS NULL AND is_identity = 'NO' AND is_generated = 'NEVER';
+	`
+	switch ActiveDialect() {
+	case MySQL:
+		pkQuery = `
+			SELECT table_name, column_name
+			FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND column_key = 'PRI';
+		`
+		requiredQuery = `
+			SELECT table_name, column_name
+			FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND is_nullable = 'NO' AND column_default IS NULL
+				AND extra NOT LIKE '%auto_increment%' AND COALESCE(generation_expression, '') = '';
+		`
+	case SQLite:
+		pkQuery = `
+			SELECT m.name, p.name
+			FROM sqlite_master m
+			JOIN pragma_table_info(m.name) p
+			WHERE m.type = 'table' AND p.pk > 0;
+		`
+		// pragma_table_info leaves out generated columns, and an INTEGER
+		// PRIMARY KEY is numbered by SQLite
+		requiredQuery = `
+			SELECT m.name, p.name
+			FROM sqlite_master m
+			JOIN pragma_table_info(m.name) p
+			WHERE m.type = 'table' AND p."notnull" AND p.dflt_value IS NULL
+				AND NOT (p.pk > 0 AND lower(p.type) = 'integer');
+		`
+	}
+	pkColumns, err := tableColumns(ctx, pkQuery)
+	if err != nil {
+		return nil, err
+	}
+	required, err := tableColumns(ctx, requiredQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	var joins []JoinTable
+	for table, refs := range byTable {
+		if len(refs) != 2 || refs[0].Column == refs[1].Column {
+			continue
+		}
+		keys := []string{refs[0].Column, refs[1].Column}
+		pk := pkColumns[table]
+		if len(pk) != 2 || !slices.Contains(pk, keys[0]) || !slices.Contains(pk, keys[1]) {
+			continue
+		}
+		if slices.ContainsFunc(required[table], func(col string) bool { return !slices.Contains(keys, col) }) {
+			continue
+		}
+		joins = append(joins, JoinTable{Table: table, Left: refs[0], Right: refs[1]})
+	}
+	sort.Slice(joins, func(i, j int) bool { return joins[i].Table < joins[j].Table })
+	return joins, nil
+}
+
+// tableColumns runs a query returning table and column name pairs and
+// groups the columns by table.
+func tableColumns(ctx context.Context, query string) (map[string][]string, error) {
+	rows, err := QueryLogged(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string][]string)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		columns[table] = append(columns[table], column)
+	}
+	return columns, rows.Err()
+}
+
+// PairKeys picks n distinct random pairs of a left and a right key, or
+// every pair when there are no more than n.
+func PairKeys(left, right []string, n int) [][2]string {
+	total := len(left) * len(right)
+	if n <= 0 || total == 0 {
+		return nil
+	}
+	if n >= total/2 {
+		// Dense: shuffle every pair and take the first n
+		all := make([][2]string, 0, total)
+		for _, l := range left {
+			for _, r := range right {
+				all = append(all, [2]string{l, r})
+			}
+		}
+		rand.Shuffle(len(all), func(i, j int) { all[i], all[j] = all[j], all[i] })
+		return all[:min(n, total)]
+	}
+
+	seen := make(map[[2]int]bool, n)
+	pairs := make([][2]string, 0, n)
+	for len(pairs) < n {
+		i, j := rand.Intn(len(left)), rand.Intn(len(right))
+		if seen[[2]int{i, j}] {
+			continue
+		}
+		seen[[2]int{i, j}] = true
+		pairs = append(pairs, [2]string{left[i], right[j]})
+	}
+	return pairs
+}
+
+// InsertJoinRows inserts up to n distinct pairings of the keys the join
+// table's parents hold in tx, including rows inserted earlier in it, and
+// returns how many rows were inserted. Pairs the table already holds are
+// skipped, so fewer rows may be inserted than asked for.
+func InsertJoinRows(ctx context.Context, tx *sql.Tx, jt JoinTable, n int) (int64, error) {
+	left, err := parentKeys(ctx, tx, jt.Left)
+	if err != nil {
+		return 0, err
+	}
+	right, err := parentKeys(ctx, tx, jt.Right)
+	if err != nil {
+		return 0, err
+	}
+	pairs := PairKeys(left, right, n)
+	if len(pairs) == 0 {
+		return 0, nil
+	}
+
+	ins := &Insert{
+		Table:   QuoteIdentifier(jt.Table),
+		Columns: []string{QuoteIdentifier(jt.Left.Column), QuoteIdentifier(jt.Right.Column)},
+		Suffix:  "ON CONFLICT DO NOTHING",
+	}
+	for _, p := range pairs {
+		ins.Rows = append(ins.Rows, []string{QuoteLiteral(p[0]), QuoteLiteral(p[1])})
+	}
+	stmt := ins.String()
+	if ActiveDialect() == MySQL {
+		// MySQL has no ON CONFLICT, but INSERT IGNORE skips conflicting rows
+		ins.Suffix = ""
+		stmt = "INSERT IGNORE" + strings.TrimPrefix(ins.String(), "INSERT")
+	}
+	res, err := tx.ExecContext(ctx, stmt)
+	if err != nil {
+		return 0, fmt.Errorf("inserting into %s: %w", jt.Table, err)
+	}
+	return res.RowsAffected()
+}
+
+// parentKeys reads up to maxJoinParentKeys random keys referenced by fk.
+func parentKeys(ctx context.Context, tx *sql.Tx, fk ForeignKey) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, randomValuesQuery(fk.RefTable, fk.RefColumn), maxJoinParentKeys)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s.%s: %w", fk.RefTable, fk.RefColumn, err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		keys = append(keys, v)
+	}
+	return keys, rows.Err()
+}
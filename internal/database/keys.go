@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// PrimaryKey is a table's single-column primary key.
+type PrimaryKey struct {
+	Column   string
+	DataType string
+	// AutoIncrement is set for serial, identity, AUTO_INCREMENT and SQLite
+	// INTEGER PRIMARY KEY columns, which the database numbers itself when
+	// they are left out of an INSERT.
+	AutoIncrement bool
+}
+
+// GetPrimaryKey returns the primary key of a table, or nil when it has none
+// or the key spans several columns.
+func GetPrimaryKey(ctx context.Context, table string) (*PrimaryKey, error) {
+	query := `
+		SELECT c.column_name, c.data_type,
+			COALESCE(c.column_default LIKE 'nextval(%', false) OR c.is_identity = 'YES'
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON kcu.constraint_schema = tc.constraint_schema AND kcu.constraint_name = tc.constraint_name
+		JOIN information_schema.columns c
+			ON c.table_schema = tc.table_schema AND c.table_name = tc.table_name AND c.column_name = kcu.column_name
+		WHERE tc.table_schema = current_schema() AND tc.table_name = $1 AND tc.constraint_type = 'PRIMARY KEY';
+	`
+	switch ActiveDialect() {
+	case MySQL:
+		query = `
+			SELECT column_name, data_type, extra LIKE '%auto_increment%'
+			FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = ? AND column_key = 'PRI';
+		`
+	case SQLite:
+		// An INTEGER PRIMARY KEY is an alias for the rowid, which SQLite
+		// numbers itself
+		query = `SELECT name, lower(type), lower(type) = 'integer' FROM pragma_table_info(?) WHERE pk > 0;`
+	}
+	rows, err := QueryLogged(ctx, query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []PrimaryKey
+	for rows.Next() {
+		var pk PrimaryKey
+		if err := rows.Scan(&pk.Column, &pk.DataType, &pk.AutoIncrement); err != nil {
+			return nil, err
+		}
+		keys = append(keys, pk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) != 1 {
+		return nil, nil
+	}
+	return &keys[0], nil
+}
+
+// IsInteger reports whether the key holds whole numbers that can be
+// continued from the current maximum.
+func (pk *PrimaryKey) IsInteger() bool {
+	switch pk.DataType {
+	case "smallint", "integer", "bigint", "int", "tinyint", "mediumint":
+		return true
+	}
+	return false
+}
+
+// MaxValue returns the largest value of an integer column, or 0 when the
+// table is empty.
+func MaxValue(ctx context.Context, table, column string) (int64, error) {
+	var max sql.NullInt64
+	query := fmt.Sprintf("SELECT max(%s) FROM %s", QuoteIdentifier(column), QuoteIdentifier(table))
+	if err := QueryRowLogged(ctx, query).Scan(&max); err != nil {
+		return 0, err
+	}
+	return max.Int64, nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EnforceLimit caps the rows a query can return at max. A query without a
+// LIMIT gets one appended, and a LIMIT larger than max, or LIMIT ALL, is
+// lowered to it; a smaller one is kept. Queries whose row count is set some
+// other way, such as FETCH FIRST or a LIMIT expression, are wrapped in an
+// outer SELECT with the limit. A max of 0 or less leaves sql unchanged.
+func EnforceLimit(sql string, max int) string {
+	if max <= 0 {
+		return sql
+	}
+	tokens := tokenize(sql)
+	for len(tokens) > 0 && tokens[len(tokens)-1].isPunct(";") {
+		sql = sql[:tokens[len(tokens)-1].pos]
+		tokens = tokens[:len(tokens)-1]
+	}
+	sql = strings.TrimSpace(sql)
+	if len(tokens) == 0 {
+		return sql
+	}
+
+	// Only clauses of the outermost query count, not those of subqueries
+	limitAt, fetch, depth := -1, false, 0
+	for i, tok := range tokens {
+		switch {
+		case tok.isPunct("("):
+			depth++
+		case tok.isPunct(")"):
+			depth--
+		case depth == 0 && tok.isWord("LIMIT"):
+			limitAt = i
+		case depth == 0 && tok.isWord("FETCH"):
+			fetch = true
+		}
+	}
+
+	switch {
+	case fetch:
+	case limitAt < 0:
+		// On a line of its own, so a trailing comment can't swallow it
+		return fmt.Sprintf("%s\nLIMIT %d", sql, max)
+	case limitAt+1 < len(tokens):
+		count := tokens[limitAt+1]
+		end := count.pos + len(count.text)
+		// The count must be the whole expression, not e.g. LIMIT 10 * 10
+		if limitAt+2 < len(tokens) && !tokens[limitAt+2].isWord("OFFSET") {
+			break
+		}
+		if count.kind == tokenNumber {
+			if n, err := strconv.Atoi(count.text); err == nil {
+				if n <= max {
+					return sql
+				}
+				return sql[:count.pos] + strconv.Itoa(max) + sql[end:]
+			}
+		}
+		if count.isWord("ALL") {
+			return sql[:count.pos] + strconv.Itoa(max) + sql[end:]
+		}
+	}
+	return fmt.Sprintf("SELECT * FROM (\n%s\n) AS limited_query LIMIT %d", sql, max)
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Rules are naming conventions for CheckNamingConventions: patterns that
+// table names, column names and the names of foreign key columns must
+// match. A nil pattern isn't checked.
+type Rules struct {
+	Table      *regexp.Regexp
+	Column     *regexp.Regexp
+	ForeignKey *regexp.Regexp
+}
+
+// Violation is a name that breaks one of the Rules.
+type Violation struct {
+	Table   string `json:"table"`
+	Column  string `json:"column,omitempty"`
+	Rule    string `json:"rule"` // table, column or foreignKey
+	Message string `json:"message"`
+}
+
+// tableConstraintWords start the elements of a CREATE TABLE that are
+// constraints rather than column definitions.
+var tableConstraintWords = []string{"CONSTRAINT", "PRIMARY", "UNIQUE", "FOREIGN", "CHECK", "EXCLUDE", "LIKE"}
+
+// CheckNamingConventions checks the tables and columns created by the
+// CREATE TABLE statements in ddl against rules. Names are checked as
+// written, before PostgreSQL folds unquoted ones to lower case, so
+// CustomerOrders is reported even though it would be created as
+// customerorders. Foreign key columns are those with an inline REFERENCES
+// and those listed in a FOREIGN KEY table constraint.
+func CheckNamingConventions(ddl string, rules Rules) []Violation {
+	tokens := tokenize(ddl)
+	name := func(tok token) string {
+		if tok.kind == tokenWord {
+			return ddl[tok.pos : tok.pos+len(tok.text)]
+		}
+		return tok.text
+	}
+
+	var violations []Violation
+	for i := 0; i < len(tokens); i++ {
+		if !tokens[i].isWord("CREATE") {
+			continue
+		}
+		// CREATE [TEMP | TEMPORARY | UNLOGGED] TABLE [IF NOT EXISTS] name (
+		j := i + 1
+		if j < len(tokens) && tokens[j].isWord("TEMP", "TEMPORARY", "UNLOGGED") {
+			j++
+		}
+		if j >= len(tokens) || !tokens[j].isWord("TABLE") {
+			continue
+		}
+		j++
+		if j+2 < len(tokens) && tokens[j].isWord("IF") && tokens[j+1].isWord("NOT") && tokens[j+2].isWord("EXISTS") {
+			j += 3
+		}
+		// A schema-qualified name is checked without its schema
+		for j+2 < len(tokens) && tokens[j+1].isPunct(".") {
+			j += 2
+		}
+		if j+1 >= len(tokens) || !tokens[j+1].isPunct("(") {
+			continue
+		}
+		table := name(tokens[j])
+		if rules.Table != nil && !rules.Table.MatchString(table) {
+			violations = append(violations, Violation{
+				Table:   table,
+				Rule:    "table",
+				Message: fmt.Sprintf("table name %s does not match %s", table, rules.Table),
+			})
+		}
+
+		// Split the body into its comma-separated elements
+		var elements [][]token
+		start, depth := j+2, 1
+		for j += 2; j < len(tokens) && depth > 0; j++ {
+			switch {
+			case tokens[j].isPunct("("):
+				depth++
+			case tokens[j].isPunct(")"):
+				depth--
+			}
+			if depth == 0 || (depth == 1 && tokens[j].isPunct(",")) {
+				elements = append(elements, tokens[start:j])
+				start = j + 1
+			}
+		}
+		i = j - 1
+
+		var columns []string
+		foreignKeys := make(map[string]bool)
+		for _, elem := range elements {
+			if len(elem) == 0 {
+				continue
+			}
+			if elem[0].isWord(tableConstraintWords...) {
+				// FOREIGN KEY (a, b) REFERENCES ...
+				for k := 0; k+2 < len(elem); k++ {
+					if !elem[k].isWord("FOREIGN") || !elem[k+1].isWord("KEY") || !elem[k+2].isPunct("(") {
+						continue
+					}
+					for k += 3; k < len(elem) && !elem[k].isPunct(")"); k++ {
+						if !elem[k].isPunct(",") {
+							foreignKeys[name(elem[k])] = true
+						}
+					}
+				}
+				continue
+			}
+			column := name(elem[0])
+			columns = append(columns, column)
+			for _, tok := range elem[1:] {
+				if tok.isWord("REFERENCES") {
+					foreignKeys[column] = true
+				}
+			}
+		}
+
+		for _, column := range columns {
+			if rules.Column != nil && !rules.Column.MatchString(column) {
+				violations = append(violations, Violation{
+					Table:   table,
+					Column:  column,
+					Rule:    "column",
+					Message: fmt.Sprintf("column name %s.%s does not match %s", table, column, rules.Column),
+				})
+			}
+			if foreignKeys[column] && rules.ForeignKey != nil && !rules.ForeignKey.MatchString(column) {
+				violations = append(violations, Violation{
+					Table:   table,
+					Column:  column,
+					Rule:    "foreignKey",
+					Message: fmt.Sprintf("foreign key column %s.%s does not match %s", table, column, rules.ForeignKey),
+				})
+			}
+		}
+	}
+	return violations
+}
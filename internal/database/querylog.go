@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"strings"
+	"time"
+)
+
+// SlowQueryThreshold is how long a query run through QueryLogged or
+// QueryRowLogged may take before it is logged; zero disables the log.
+var SlowQueryThreshold time.Duration
+
+// maxLoggedQuery caps how much of a slow query's SQL is logged.
+const maxLoggedQuery = 500
+
+// QueryLogged runs query on the Reader pool, logging it with its duration
+// when it is slow. The duration covers execution up to the first rows, not
+// reading them.
+func QueryLogged(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := Reader(ctx).QueryContext(ctx, query, args...)
+	logIfSlow(query, time.Since(start))
+	return rows, err
+}
+
+// QueryRowLogged is QueryLogged for queries returning at most one row.
+func QueryRowLogged(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := Reader(ctx).QueryRowContext(ctx, query, args...)
+	logIfSlow(query, time.Since(start))
+	return row
+}
+
+// QueryReadOnly is QueryLogged inside a read-only transaction, for SQL
+// written by users or the model: should a write get past the lexical
+// checks, the database refuses it. SQLite ignores the read-only option of
+// transactions, so there the connection itself is made read-only until
+// done is called. Call done once the rows are read.
+func QueryReadOnly(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, done func(), err error) {
+	conn, err := Reader(ctx).Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	sqlite := ActiveDialect() == SQLite
+	release := func() {
+		if sqlite {
+			if _, err := conn.ExecContext(context.Background(), "PRAGMA query_only = OFF"); err != nil {
+				// Discard the connection rather than pool a read-only one
+				conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+			}
+		}
+		conn.Close()
+	}
+	if sqlite {
+		if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+			release()
+			return nil, nil, err
+		}
+	}
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	start := time.Now()
+	rows, err = tx.QueryContext(ctx, query, args...)
+	logIfSlow(query, time.Since(start))
+	if err != nil {
+		tx.Rollback()
+		release()
+		return nil, nil, err
+	}
+	return rows, func() {
+		rows.Close()
+		tx.Rollback()
+		release()
+	}, nil
+}
+
+// logIfSlow logs query, on one line and truncated, when elapsed exceeds
+// SlowQueryThreshold.
+func logIfSlow(query string, elapsed time.Duration) {
+	if SlowQueryThreshold <= 0 || elapsed < SlowQueryThreshold {
+		return
+	}
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > maxLoggedQuery {
+		query = query[:maxLoggedQuery] + "..."
+	}
+	log.Printf("slow query (%s): %s", elapsed.Round(time.Millisecond), query)
+}
@@ -0,0 +1,116 @@
+package database
+
+// writeKeywords begin statements or clauses that change data, the schema or
+// server state. SELECT ... INTO creates a table, and DO only follows ON
+// CONFLICT in an INSERT or starts an anonymous code block.
+var writeKeywords = []string{
+	"INSERT", "UPDATE", "DELETE", "MERGE", "TRUNCATE", "DROP", "ALTER",
+	"CREATE", "GRANT", "REVOKE", "COPY", "CALL", "DO", "VACUUM", "REINDEX",
+	"REFRESH", "INTO",
+}
+
+// writeFunctions have side effects even when called from a SELECT.
+var writeFunctions = []string{
+	"NEXTVAL", "SETVAL", "SET_CONFIG", "PG_TERMINATE_BACKEND",
+	"PG_CANCEL_BACKEND", "PG_RELOAD_CONF", "LO_IMPORT", "LO_EXPORT",
+	"LO_UNLINK", "DBLINK", "DBLINK_EXEC",
+}
+
+// IsReadOnlyStatement reports whether sql is a single statement that only
+// reads: a SELECT, VALUES or TABLE, possibly with CTEs, whose every CTE body
+// is itself such a query. PostgreSQL runs data-modifying CTEs such as
+// WITH gone AS (DELETE FROM users RETURNING *) SELECT * FROM gone even
+// though the statement starts like a read, so CTE bodies are checked, and
+// write keywords or side-effecting functions anywhere, including
+// subqueries, are rejected. Keywords inside string literals and quoted
+// identifiers don't count.
+func IsReadOnlyStatement(sql string) bool {
+	tokens := tokenize(sql)
+	for len(tokens) > 0 && tokens[len(tokens)-1].isPunct(";") {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) == 0 {
+		return false
+	}
+
+	for i, tok := range tokens {
+		switch {
+		case tok.isPunct(";"):
+			return false // more than one statement
+		case tok.isWord(writeKeywords...):
+			return false
+		case tok.isWord(writeFunctions...) && i+1 < len(tokens) && tokens[i+1].isPunct("("):
+			return false
+		}
+	}
+	return isReadQuery(tokens)
+}
+
+// isReadQuery reports whether tokens start a read-only query, checking the
+// body of every CTE of a WITH clause.
+func isReadQuery(tokens []token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	switch {
+	case tokens[0].isWord("SELECT", "VALUES", "TABLE"):
+		return true
+	case tokens[0].isPunct("("):
+		return isReadQuery(tokens[1:])
+	case !tokens[0].isWord("WITH"):
+		return false
+	}
+
+	i := 1
+	if i < len(tokens) && tokens[i].isWord("RECURSIVE") {
+		i++
+	}
+	for {
+		// name [(columns)] AS [NOT] [MATERIALIZED] (body)
+		if i >= len(tokens) || (tokens[i].kind != tokenWord && tokens[i].kind != tokenIdentifier) {
+			return false
+		}
+		i++
+		if i < len(tokens) && tokens[i].isPunct("(") {
+			i = closingToken(tokens, i) + 1
+		}
+		if i >= len(tokens) || !tokens[i].isWord("AS") {
+			return false
+		}
+		i++
+		for i < len(tokens) && tokens[i].isWord("NOT", "MATERIALIZED") {
+			i++
+		}
+		if i >= len(tokens) || !tokens[i].isPunct("(") {
+			return false
+		}
+		end := closingToken(tokens, i)
+		if end < 0 || !isReadQuery(tokens[i+1:end]) {
+			return false
+		}
+		i = end + 1
+		if i < len(tokens) && tokens[i].isPunct(",") {
+			i++
+			continue
+		}
+		return isReadQuery(tokens[i:])
+	}
+}
+
+// closingToken returns the index of the parenthesis closing the one at
+// tokens[open], or -1.
+func closingToken(tokens []token, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch {
+		case tokens[i].isPunct("("):
+			depth++
+		case tokens[i].isPunct(")"):
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,36 @@
+package database
+
+import "testing"
+
+func TestIsReadOnlyStatement(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		readOnly bool
+	}{
+		{"select", "SELECT * FROM t", true},
+		{"trailing semicolon", "SELECT * FROM t;", true},
+		{"values", "VALUES (1), (2)", true},
+		{"parenthesized", "(SELECT 1) UNION (SELECT 2)", true},
+		{"read-only CTE", "WITH x AS (SELECT * FROM t) SELECT * FROM x", true},
+		{"writable CTE", "WITH x AS (DELETE FROM t RETURNING *) SELECT * FROM x", false},
+		{"writable CTE after a read-only one", "WITH a AS (SELECT 1), b AS (UPDATE t SET n = 1 RETURNING *) SELECT * FROM b", false},
+		{"write in a subquery", "SELECT * FROM (INSERT INTO t VALUES (1) RETURNING *) x", false},
+		{"side-effecting function", "SELECT nextval('t_id_seq')", false},
+		{"select into", "SELECT * INTO copy FROM t", false},
+		{"two statements", "SELECT 1; SELECT 2", false},
+		{"keyword in a string", "SELECT * FROM t WHERE note = 'delete me'", true},
+		{"keyword in a quoted identifier", `SELECT "delete" FROM t`, true},
+		{"keyword in an E-string", `SELECT E'don\'t DELETE' FROM t`, true},
+		{"E-string hiding a second statement", `SELECT E'\''; DELETE FROM t; --'`, false},
+		{"not a query", "DELETE FROM t", false},
+		{"empty", " ; ", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsReadOnlyStatement(tt.sql); got != tt.readOnly {
+				t.Errorf("IsReadOnlyStatement(%q) = %v, want %v", tt.sql, got, tt.readOnly)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package database
+
+import "strings"
+
+// clauseKeywords end a FROM item; a word in this list is never a table alias.
+var clauseKeywords = map[string]bool{
+	"WHERE": true, "GROUP": true, "ORDER": true, "LIMIT": true, "OFFSET": true,
+	"HAVING": true, "WINDOW": true, "UNION": true, "INTERSECT": true, "EXCEPT": true,
+	"JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true, "FULL": true,
+	"CROSS": true, "NATURAL": true, "ON": true, "USING": true, "FETCH": true,
+	"FOR": true, "RETURNING": true, "SET": true, "VALUES": true, "SELECT": true,
+	"TABLESAMPLE": true,
+}
+
+// tableReadingFunctions read tables named, or run queries written, in their
+// string arguments, which ReferencedTables can't see.
+var tableReadingFunctions = []string{
+	"QUERY_TO_XML", "QUERY_TO_XMLSCHEMA", "QUERY_TO_XML_AND_XMLSCHEMA",
+	"TABLE_TO_XML", "TABLE_TO_XMLSCHEMA", "TABLE_TO_XML_AND_XMLSCHEMA",
+	"SCHEMA_TO_XML", "SCHEMA_TO_XMLSCHEMA", "SCHEMA_TO_XML_AND_XMLSCHEMA",
+	"DATABASE_TO_XML", "DATABASE_TO_XMLSCHEMA", "DATABASE_TO_XML_AND_XMLSCHEMA",
+	"CURSOR_TO_XML", "CURSOR_TO_XMLSCHEMA", "TS_STAT", "DBLINK",
+	"PG_READ_FILE", "PG_READ_BINARY_FILE",
+}
+
+// cte is a WITH query: its name, the tokens of its body, and the end of the
+// query it belongs to, up to which the name is visible.
+type cte struct {
+	name                    string
+	bodyStart, bodyEnd, end int
+	recursive               bool
+}
+
+// hides reports whether the CTE is what an unqualified name at token i
+// refers to. Outside its own body the name is visible to the rest of its
+// query; inside it, only when the CTE is recursive.
+func (c cte) hides(i int) bool {
+	if c.recursive {
+		return i > c.bodyStart && i < c.end
+	}
+	return i > c.bodyEnd && i < c.end
+}
+
+// ReferencedTables returns the tables a query reads from, in order of first
+// appearance. Unquoted names are lowercased as Postgres does, and
+// schema-qualified names keep their schema, e.g. other.secret. Names that
+// refer to a WITH query where they appear are not included; a CTE named like
+// a table doesn't hide that table in the CTE's own body or elsewhere.
+func ReferencedTables(sql string) []string {
+	tokens := tokenize(sql)
+	match := matchParens(tokens)
+	ctes := findCTEs(tokens, match)
+
+	var tables []string
+	seen := make(map[string]bool)
+	add := func(name string, qualified bool, at int) {
+		if !qualified {
+			for _, c := range ctes {
+				if c.name == name && c.hides(at) {
+					return
+				}
+			}
+		}
+		if !seen[name] {
+			seen[name] = true
+			tables = append(tables, name)
+		}
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		if !tokens[i].isWord("FROM", "JOIN", "INTO", "UPDATE", "TABLE") {
+			continue
+		}
+		isFrom := tokens[i].isWord("FROM")
+
+		for j := i + 1; j < len(tokens); {
+			if tokens[j].isWord("ONLY", "LATERAL") {
+				j++
+				continue
+			}
+			if tokens[j].isPunct("(") {
+				// A subquery; its own FROM is found by the outer loop
+				j = match[j] + 1
+			} else {
+				name, qualified, next := readTableRef(tokens, j)
+				switch {
+				case name != "":
+					add(name, qualified, j)
+					j = next
+				case next < len(tokens) && tokens[next].isPunct("("):
+					j = match[next] + 1 // a function call
+				default:
+					j = len(tokens)
+				}
+			}
+			if !isFrom || j >= len(tokens) {
+				break
+			}
+			// Skip an alias and its column list, then continue a comma
+			// separated FROM list
+			if j < len(tokens) && tokens[j].isWord("AS") {
+				j++
+			}
+			if j < len(tokens) && isIdent(tokens[j]) && !clauseKeywords[tokens[j].text] {
+				j++
+				if j < len(tokens) && tokens[j].isPunct("(") {
+					j = match[j] + 1
+				}
+			}
+			if j < len(tokens) && tokens[j].isPunct(",") {
+				j++
+				continue
+			}
+			break
+		}
+	}
+	return tables
+}
+
+// CallsTableReadingFunction returns the first function in sql that reads
+// tables named in a string, such as query_to_xml('SELECT * FROM t', ...),
+// or "" if there is none.
+func CallsTableReadingFunction(sql string) string {
+	tokens := tokenize(sql)
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i].isWord(tableReadingFunctions...) && tokens[i+1].isPunct("(") {
+			return strings.ToLower(tokens[i].text)
+		}
+	}
+	return ""
+}
+
+// matchParens returns, for every ( token, the index of the ) closing it, or
+// len(tokens) if it is never closed. Other entries are unused.
+func matchParens(tokens []token) []int {
+	match := make([]int, len(tokens))
+	var open []int
+	for i, t := range tokens {
+		switch {
+		case t.isPunct("("):
+			open = append(open, i)
+			match[i] = len(tokens)
+		case t.isPunct(")") && len(open) > 0:
+			match[open[len(open)-1]] = i
+			open = open[:len(open)-1]
+		}
+	}
+	return match
+}
+
+// findCTEs returns the WITH queries defined anywhere in tokens. A WITH
+// clause's names are visible until the end of the parenthesized query it
+// starts, or of the statement.
+func findCTEs(tokens []token, match []int) []cte {
+	var ctes []cte
+	var open []int
+	for i := 0; i < len(tokens); i++ {
+		switch {
+		case tokens[i].isPunct("("):
+			open = append(open, i)
+			continue
+		case tokens[i].isPunct(")"):
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+			continue
+		case !tokens[i].isWord("WITH"):
+			continue
+		}
+
+		end := len(tokens)
+		if len(open) > 0 {
+			end = match[open[len(open)-1]]
+		}
+		j := i + 1
+		recursive := j < len(tokens) && tokens[j].isWord("RECURSIVE")
+		if recursive {
+			j++
+		}
+		// name [(columns)] AS [[NOT] MATERIALIZED] (body), ...
+		for j < len(tokens) && isIdent(tokens[j]) {
+			c := cte{name: identName(tokens[j]), end: end, recursive: recursive}
+			j++
+			if j < len(tokens) && tokens[j].isPunct("(") {
+				j = match[j] + 1
+			}
+			if j >= len(tokens) || !tokens[j].isWord("AS") {
+				break
+			}
+			j++
+			for j < len(tokens) && tokens[j].isWord("NOT", "MATERIALIZED") {
+				j++
+			}
+			if j >= len(tokens) || !tokens[j].isPunct("(") {
+				break
+			}
+			c.bodyStart, c.bodyEnd = j, match[j]
+			ctes = append(ctes, c)
+			j = c.bodyEnd + 1
+			if j >= len(tokens) || !tokens[j].isPunct(",") {
+				break
+			}
+			j++
+		}
+	}
+	return ctes
+}
+
+// readTableRef reads a possibly schema-qualified table name at tokens[i],
+// reporting whether it was qualified. It returns "" when the item is a
+// subquery or function call.
+func readTableRef(tokens []token, i int) (string, bool, int) {
+	if i >= len(tokens) || !isIdent(tokens[i]) || clauseKeywords[tokens[i].text] {
+		return "", false, i
+	}
+	name := identName(tokens[i])
+	qualified := false
+	i++
+	for i+1 < len(tokens) && tokens[i].isPunct(".") && isIdent(tokens[i+1]) {
+		name += "." + identName(tokens[i+1])
+		qualified = true
+		i += 2
+	}
+	if i < len(tokens) && tokens[i].isPunct("(") {
+		return "", false, i
+	}
+	return name, qualified, i
+}
+
+// UnqualifiedName strips the schema from a name returned by
+// ReferencedTables.
+func UnqualifiedName(name string) string {
+	return name[strings.LastIndexByte(name, '.')+1:]
+}
+
+func isIdent(t token) bool {
+	return t.kind == tokenWord || t.kind == tokenIdentifier
+}
+
+func identName(t token) string {
+	if t.kind == tokenIdentifier {
+		return t.text
+	}
+	return strings.ToLower(t.text)
+}
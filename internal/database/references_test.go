@@ -0,0 +1,56 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReferencedTables(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{"single table", "SELECT * FROM users", []string{"users"}},
+		{"join", "SELECT * FROM users u JOIN orders o ON o.user_id = u.id", []string{"users", "orders"}},
+		{"comma list", "SELECT * FROM users AS u, orders o", []string{"users", "orders"}},
+		{"quoted and mixed case", `SELECT * FROM "Users", Orders`, []string{"Users", "orders"}},
+		{"schema-qualified", "SELECT * FROM other.secret", []string{"other.secret"}},
+		{"subquery", "SELECT * FROM (SELECT * FROM secret) s", []string{"secret"}},
+		{"subquery in WHERE", "SELECT * FROM users WHERE id IN (SELECT user_id FROM secret)", []string{"users", "secret"}},
+		{"table after a subquery", "SELECT * FROM (SELECT 1) a, secret", []string{"secret"}},
+		{"table after a function", "SELECT * FROM generate_series(1, 3) g(n), secret", []string{"secret"}},
+		{"CTE", "WITH recent AS (SELECT * FROM orders) SELECT * FROM recent", []string{"orders"}},
+		{"CTE with columns", "WITH recent(id) AS (SELECT id FROM orders) SELECT * FROM recent", []string{"orders"}},
+		{"CTE named like the table it reads", "WITH secret AS (SELECT * FROM secret) SELECT * FROM secret", []string{"secret"}},
+		{"CTE used by a later CTE", "WITH a AS (SELECT * FROM users), b AS (SELECT * FROM a) SELECT * FROM b", []string{"users"}},
+		{"CTE before its WITH", "SELECT * FROM secret, (WITH secret AS (SELECT 1) SELECT * FROM secret) x", []string{"secret"}},
+		{"CTE doesn't hide a qualified table", "WITH secret AS (SELECT 1) SELECT * FROM other.secret", []string{"other.secret"}},
+		{"recursive CTE", "WITH RECURSIVE tree AS (SELECT id FROM nodes UNION ALL SELECT n.id FROM nodes n JOIN tree t ON n.parent = t.id) SELECT * FROM tree", []string{"nodes"}},
+		{"table name in a string", "SELECT 'FROM secret' FROM users", []string{"users"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReferencedTables(tt.sql); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReferencedTables(%q) = %q, want %q", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallsTableReadingFunction(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT query_to_xml('select * from secret', true, true, '')", "query_to_xml"},
+		{"SELECT * FROM users WHERE x = TABLE_TO_XML('secret', true, true, '')::text", "table_to_xml"},
+		{"SELECT 'query_to_xml(' FROM users", ""},
+		{"SELECT count(*) FROM users", ""},
+	}
+	for _, tt := range tests {
+		if got := CallsTableReadingFunction(tt.sql); got != tt.want {
+			t.Errorf("CallsTableReadingFunction(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package database
+
+import "strings"
+
+// reservedWords are the PostgreSQL keywords that can't be used as bare
+// table or column names.
+var reservedWords = map[string]bool{
+	"all": true, "analyse": true, "analyze": true, "and": true, "any": true,
+	"array": true, "as": true, "asc": true, "asymmetric": true,
+	"authorization": true, "binary": true, "both": true, "case": true,
+	"cast": true, "check": true, "collate": true, "collation": true,
+	"column": true, "concurrently": true, "constraint": true, "create": true,
+	"cross": true, "current_catalog": true, "current_date": true,
+	"current_role": true, "current_schema": true, "current_time": true,
+	"current_timestamp": true, "current_user": true, "default": true,
+	"deferrable": true, "desc": true, "distinct": true, "do": true,
+	"else": true, "end": true, "except": true, "false": true, "fetch": true,
+	"for": true, "foreign": true, "freeze": true, "from": true, "full": true,
+	"grant": true, "group": true, "having": true, "ilike": true, "in": true,
+	"initially": true, "inner": true, "intersect": true, "into": true,
+	"is": true, "isnull": true, "join": true, "lateral": true,
+	"leading": true, "left": true, "like": true, "limit": true,
+	"localtime": true, "localtimestamp": true, "natural": true, "not": true,
+	"notnull": true, "null": true, "offset": true, "on": true, "only": true,
+	"or": true, "order": true, "outer": true, "overlaps": true,
+	"placing": true, "primary": true, "references": true, "returning": true,
+	"right": true, "select": true, "session_user": true, "similar": true,
+	"some": true, "symmetric": true, "system_user": true, "table": true,
+	"tablesample": true, "then": true, "to": true, "trailing": true,
+	"true": true, "union": true, "unique": true, "user": true, "using": true,
+	"variadic": true, "verbose": true, "when": true, "where": true,
+	"window": true, "with": true,
+}
+
+// QuoteReservedIdentifiers double-quotes the table and column names of an
+// INSERT that the model left bare although they are reserved words, such as
+// a column named order or a table named user. Only names that exist in
+// schema are quoted, using their real spelling. Statements that don't parse
+// as INSERT ... VALUES are returned unchanged.
+func QuoteReservedIdentifiers(stmt string, schema []Table) string {
+	ins, err := ParseInsert(stmt)
+	if err != nil {
+		return stmt
+	}
+
+	var table *Table
+	for i := range schema {
+		if strings.EqualFold(schema[i].Name, ins.TableName()) {
+			table = &schema[i]
+			break
+		}
+	}
+	if table == nil {
+		return stmt
+	}
+
+	changed := false
+	parts := splitOutside(ins.Table, '.')
+	if name := strings.TrimSpace(parts[len(parts)-1]); isBareReserved(name) {
+		parts[len(parts)-1] = QuoteIdentifier(table.Name)
+		ins.Table = strings.Join(parts, ".")
+		changed = true
+	}
+	for i, name := range ins.Columns {
+		if !isBareReserved(name) {
+			continue
+		}
+		for _, col := range table.Columns {
+			if strings.EqualFold(col.Name, name) {
+				ins.Columns[i] = QuoteIdentifier(col.Name)
+				changed = true
+				break
+			}
+		}
+	}
+
+	if !changed {
+		return stmt
+	}
+	return ins.String()
+}
+
+// isBareReserved reports whether an identifier as written is unquoted and a
+// reserved word.
+func isBareReserved(name string) bool {
+	return UnquoteIdentifier(name) == name && reservedWords[strings.ToLower(name)]
+}
@@ -0,0 +1,63 @@
+package database
+
+import "testing"
+
+func TestQuoteReservedIdentifiers(t *testing.T) {
+	schema := []Table{{Name: "user", Columns: []Column{
+		{Name: "id"}, {Name: "order"}, {Name: "name"},
+	}}}
+	tests := []struct {
+		name string
+		stmt string
+		want string
+	}{
+		{
+			"reserved table and column",
+			"INSERT INTO user (id, order, name) VALUES (1, 2, 'Ann')",
+			`INSERT INTO "user" (id, "order", name) VALUES (1, 2, 'Ann')`,
+		},
+		{
+			"different case keeps the real spelling",
+			"INSERT INTO USER (ID, ORDER) VALUES (1, 2)",
+			`INSERT INTO "user" (ID, "order") VALUES (1, 2)`,
+		},
+		{
+			"already quoted",
+			`INSERT INTO "user" (id, "order") VALUES (1, 2)`,
+			`INSERT INTO "user" (id, "order") VALUES (1, 2)`,
+		},
+		{
+			"schema-qualified",
+			"INSERT INTO public.user (order) VALUES (2)",
+			`INSERT INTO public."user" ("order") VALUES (2)`,
+		},
+		{
+			"unknown table",
+			"INSERT INTO orders (order) VALUES (1)",
+			"INSERT INTO orders (order) VALUES (1)",
+		},
+		{
+			"not an INSERT",
+			"SELECT order FROM user",
+			"SELECT order FROM user",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QuoteReservedIdentifiers(tt.stmt, schema); got != tt.want {
+				t.Errorf("QuoteReservedIdentifiers(%q) = %q, want %q", tt.stmt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteReservedIdentifiersMySQL(t *testing.T) {
+	defer SetDialect(ActiveDialect())
+	SetDialect(MySQL)
+
+	schema := []Table{{Name: "user", Columns: []Column{{Name: "order"}}}}
+	got := QuoteReservedIdentifiers("INSERT INTO user (order) VALUES (1)", schema)
+	if want := "INSERT INTO `user` (`order`) VALUES (1)"; got != want {
+		t.Errorf("QuoteReservedIdentifiers = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// CountAllRows returns the exact number of rows in every table.
+func CountAllRows(ctx context.Context) (map[string]int64, error) {
+	tables, err := GetTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(tables))
+	for _, table := range tables {
+		var n int64
+		query := fmt.Sprintf("SELECT count(*) FROM %s", QuoteIdentifier(table))
+		if err := QueryRowLogged(ctx, query).Scan(&n); err != nil {
+			return nil, fmt.Errorf("counting rows in %s: %w", table, err)
+		}
+		counts[table] = n
+	}
+	return counts, nil
+}
+
+// DistributeRows splits total new rows across tables in proportion to the
+// rows they already hold, so topping up keeps the existing distribution.
+// Rounding leftovers go to the tables with the largest remainders. When
+// every table is empty the rows are split evenly. Tables that get no rows
+// are left out.
+func DistributeRows(total int, counts map[string]int64) map[string]int {
+	tables := make([]string, 0, len(counts))
+	var sum int64
+	for table, n := range counts {
+		tables = append(tables, table)
+		sum += n
+	}
+	sort.Strings(tables)
+	if len(tables) == 0 || total <= 0 {
+		return nil
+	}
+
+	weight := func(table string) float64 {
+		if sum == 0 {
+			return 1 / float64(len(tables))
+		}
+		return float64(counts[table]) / float64(sum)
+	}
+
+	targets := make(map[string]int, len(tables))
+	remainders := make(map[string]float64, len(tables))
+	assigned := 0
+	for _, table := range tables {
+		share := float64(total) * weight(table)
+		targets[table] = int(share)
+		remainders[table] = share - float64(targets[table])
+		assigned += targets[table]
+	}
+	sort.SliceStable(tables, func(i, j int) bool { return remainders[tables[i]] > remainders[tables[j]] })
+	for i := 0; assigned < total; i++ {
+		targets[tables[i%len(tables)]]++
+		assigned++
+	}
+
+	for table, n := range targets {
+		if n == 0 {
+			delete(targets, table)
+		}
+	}
+	return targets
+}
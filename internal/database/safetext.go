@@ -0,0 +1,27 @@
+package database
+
+import (
+	"strings"
+	"unicode"
+)
+
+// UnsafeTextChars are the characters that safe-text generation keeps out of
+// string values: delimiters and quotes that trip up CSV consumers and shells.
+// Control characters such as newlines and tabs are rejected as well.
+const UnsafeTextChars = ",;'\"`\\"
+
+// UnsafeTextValues returns the string literals in stmt that contain any of
+// UnsafeTextChars or a control character.
+func UnsafeTextValues(stmt string) []string {
+	var unsafe []string
+	for _, tok := range tokenize(stmt) {
+		if tok.kind != tokenString {
+			continue
+		}
+		value := strings.ReplaceAll(tok.text, "''", "'")
+		if strings.ContainsAny(value, UnsafeTextChars) || strings.ContainsFunc(value, unicode.IsControl) {
+			unsafe = append(unsafe, value)
+		}
+	}
+	return unsafe
+}
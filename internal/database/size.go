@@ -0,0 +1,96 @@
+package database
+
+// rowOverheadBytes approximates PostgreSQL's per-row storage overhead: the
+// tuple header plus its line pointer.
+const rowOverheadBytes = 28
+
+// EstimateRowSize roughly estimates the bytes a VALUES tuple takes on disk,
+// from the length of its literals plus the per-row overhead.
+func EstimateRowSize(row []string) int {
+	size := rowOverheadBytes
+	for _, v := range row {
+		// Quotes and escapes aren't stored, but a varlena header is, so the
+		// raw literal length is close enough
+		size += len(v)
+	}
+	return size
+}
+
+// TrimToBudget keeps the leading rows of an INSERT that fit in budget bytes,
+// as estimated by EstimateRowSize. It returns the statement to run, its
+// estimated size and whether rows were dropped. The statement is empty when
+// not even one row fits. Statements that can't be parsed are estimated from
+// their length and kept or dropped as a whole.
+func TrimToBudget(stmt string, budget int) (string, int, bool) {
+	ins, err := ParseInsert(stmt)
+	if err != nil {
+		if len(stmt) > budget {
+			return "", 0, true
+		}
+		return stmt, len(stmt), false
+	}
+
+	size := 0
+	for i, row := range ins.Rows {
+		rowSize := EstimateRowSize(row)
+		if size+rowSize > budget {
+			if i == 0 {
+				return "", 0, true
+			}
+			ins.Rows = ins.Rows[:i]
+			return ins.String(), size, true
+		}
+		size += rowSize
+	}
+	return stmt, size, false
+}
+
+// ChunkInsertValues splits a multi-row INSERT into statements of at most
+// maxBytes each, for databases that limit the size of a statement or
+// packet. Every chunk repeats the table, column list and any suffix such as
+// ON CONFLICT. A single row too large for maxBytes on its own still becomes
+// a statement of its own rather than being dropped. Statements that fit,
+// can't be parsed, or a maxBytes of 0 or less leave stmt as the only result.
+func ChunkInsertValues(stmt string, maxBytes int) []string {
+	if maxBytes <= 0 || len(stmt) <= maxBytes {
+		return []string{stmt}
+	}
+	ins, err := ParseInsert(stmt)
+	if err != nil || len(ins.Rows) < 2 {
+		return []string{stmt}
+	}
+
+	rows := ins.Rows
+	ins.Rows = nil
+	base := len(ins.String())
+	rowSize := func(row []string) int {
+		size := 2 // parentheses
+		for i, v := range row {
+			if i > 0 {
+				size += 2 // ", "
+			}
+			size += len(v)
+		}
+		return size
+	}
+
+	var chunks []string
+	var chunk [][]string
+	size := base
+	for _, row := range rows {
+		n := rowSize(row)
+		if len(chunk) > 0 {
+			n += 2 // ", " before the tuple
+		}
+		if len(chunk) > 0 && size+n > maxBytes {
+			ins.Rows = chunk
+			chunks = append(chunks, ins.String())
+			chunk, size = nil, base
+			n -= 2
+		}
+		chunk = append(chunk, row)
+		size += n
+	}
+	ins.Rows = chunk
+	return append(chunks, ins.String())
+}
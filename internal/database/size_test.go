@@ -0,0 +1,62 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChunkInsertValues(t *testing.T) {
+	// The statement is 38 bytes; without rows it is 25, so each chunk of
+	// two rows is 33 bytes and a chunk of one is 28
+	const stmt = "INSERT INTO t (n) VALUES (1), (2), (3)"
+	const (
+		one   = "INSERT INTO t (n) VALUES (1)"
+		two   = "INSERT INTO t (n) VALUES (2)"
+		three = "INSERT INTO t (n) VALUES (3)"
+		pair  = "INSERT INTO t (n) VALUES (1), (2)"
+	)
+	tests := []struct {
+		name     string
+		maxBytes int
+		want     []string
+	}{
+		{"no limit", 0, []string{stmt}},
+		{"exactly the statement size", len(stmt), []string{stmt}},
+		{"one above the statement size", len(stmt) + 1, []string{stmt}},
+		{"one below the statement size", len(stmt) - 1, []string{pair, three}},
+		{"exactly two rows", len(pair), []string{pair, three}},
+		{"one above two rows", len(pair) + 1, []string{pair, three}},
+		{"one below two rows", len(pair) - 1, []string{one, two, three}},
+		{"rows larger than the limit", 10, []string{one, two, three}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ChunkInsertValues(stmt, tt.maxBytes)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ChunkInsertValues(%d) = %q, want %q", tt.maxBytes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkInsertValuesKeepsSuffix(t *testing.T) {
+	stmt := "INSERT INTO t (s) VALUES ('a, b'), ('c') ON CONFLICT DO NOTHING"
+	want := []string{
+		"INSERT INTO t (s) VALUES ('a, b') ON CONFLICT DO NOTHING",
+		"INSERT INTO t (s) VALUES ('c') ON CONFLICT DO NOTHING",
+	}
+	if got := ChunkInsertValues(stmt, len(want[0])); !reflect.DeepEqual(got, want) {
+		t.Errorf("ChunkInsertValues = %q, want %q", got, want)
+	}
+}
+
+func TestChunkInsertValuesUnchanged(t *testing.T) {
+	for _, stmt := range []string{
+		"INSERT INTO t (n) VALUES (1)",      // a single row
+		"INSERT INTO t (n) SELECT n FROM u", // not INSERT ... VALUES
+	} {
+		if got := ChunkInsertValues(stmt, 5); !reflect.DeepEqual(got, []string{stmt}) {
+			t.Errorf("ChunkInsertValues(%q) = %q, want it unchanged", stmt, got)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// openSQLite connects the package to a new SQLite database holding schema,
+// as InitDB does at startup, and restores the previous state afterwards.
+func openSQLite(t *testing.T, schema string) context.Context {
+	t.Helper()
+	prevDB, prevDialect := DB, ActiveDialect()
+	t.Cleanup(func() {
+		DB.Close()
+		DB = prevDB
+		SetDialect(prevDialect)
+	})
+
+	if err := InitDB("sqlite:"+filepath.Join(t.TempDir(), "test.db"), "", ""); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := DB.ExecContext(ctx, schema); err != nil {
+		t.Fatal(err)
+	}
+	return ctx
+}
+
+const sqliteSchema = `
+	CREATE TABLE users (
+		id INTEGER PRIMARY KEY,
+		email varchar(50) NOT NULL UNIQUE,
+		name TEXT,
+		name_upper TEXT GENERATED ALWAYS AS (upper(name)) STORED
+	);
+	CREATE TABLE roles (code TEXT PRIMARY KEY);
+	CREATE TABLE user_roles (
+		user_id INTEGER NOT NULL REFERENCES users,
+		role_code TEXT NOT NULL REFERENCES roles(code),
+		PRIMARY KEY (user_id, role_code)
+	);
+	INSERT INTO users (id, email, name) VALUES (1, 'a@example.com', 'Ann'), (2, 'b@example.com', 'Bob');
+	INSERT INTO roles (code) VALUES ('admin');
+`
+
+func TestSQLiteIntrospection(t *testing.T) {
+	ctx := openSQLite(t, sqliteSchema)
+
+	if ActiveDialect() != SQLite {
+		t.Fatalf("InitDB set the dialect to %s, want sqlite", ActiveDialect())
+	}
+
+	tables, err := GetTables(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"roles", "user_roles", "users"}; !reflect.DeepEqual(tables, want) {
+		t.Errorf("GetTables = %v, want %v", tables, want)
+	}
+	if !IsValidTableName(ctx, "users") || IsValidTableName(ctx, "nope") {
+		t.Error("IsValidTableName doesn't match the tables")
+	}
+
+	schema, err := GetStructuredSchema(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users Table
+	for _, table := range schema {
+		if table.Name == "users" {
+			users = table
+		}
+	}
+	wantUsers := []Column{
+		{Name: "id", DataType: "integer", Nullable: true, OrdinalPosition: 1},
+		{Name: "email", DataType: "varchar", MaxLength: 50, OrdinalPosition: 2},
+		{Name: "name", DataType: "text", Nullable: true, OrdinalPosition: 3},
+		{Name: "name_upper", DataType: "text", Generated: true, Nullable: true, OrdinalPosition: 4},
+	}
+	if !reflect.DeepEqual(users.Columns, wantUsers) {
+		t.Errorf("users columns = %+v, want %+v", users.Columns, wantUsers)
+	}
+
+	pk, err := GetPrimaryKey(ctx, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pk == nil || pk.Column != "id" || !pk.AutoIncrement || !pk.IsInteger() {
+		t.Errorf("GetPrimaryKey(users) = %+v, want an auto-numbered integer id", pk)
+	}
+
+	uniques, err := GetUniqueColumns(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"email", "id"}; !reflect.DeepEqual(uniques["users"], want) {
+		t.Errorf("unique columns of users = %v, want %v", uniques["users"], want)
+	}
+
+	fks, err := GetForeignKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFKs := []ForeignKey{
+		{Table: "user_roles", Column: "user_id", RefTable: "users", RefColumn: "id"},
+		{Table: "user_roles", Column: "role_code", RefTable: "roles", RefColumn: "code"},
+	}
+	if !reflect.DeepEqual(fks, wantFKs) {
+		t.Errorf("GetForeignKeys = %+v, want %+v", fks, wantFKs)
+	}
+
+	joins, err := FindJoinTables(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(joins) != 1 || joins[0].Table != "user_roles" {
+		t.Errorf("FindJoinTables = %+v, want user_roles", joins)
+	}
+
+	values, err := SampleValues(ctx, "users", "id", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 {
+		t.Errorf("SampleValues = %v, want both ids", values)
+	}
+}
+
+func TestSQLiteQueryReadOnly(t *testing.T) {
+	ctx := openSQLite(t, sqliteSchema)
+
+	rows, done, err := QueryReadOnly(ctx, "INSERT INTO roles (code) VALUES ('guest') RETURNING code")
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		done()
+	}
+	if err == nil {
+		t.Error("a write ran through QueryReadOnly")
+	}
+
+	// The connection goes back to the pool writable
+	if _, err := DB.ExecContext(ctx, "INSERT INTO roles (code) VALUES ('editor')"); err != nil {
+		t.Errorf("writing after QueryReadOnly: %v", err)
+	}
+}
+
+func TestUserHistoryTableListed(t *testing.T) {
+	ctx := openSQLite(t, "CREATE TABLE query_history (id INTEGER PRIMARY KEY, note TEXT);")
+
+	tables, err := GetTables(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"query_history"}; !reflect.DeepEqual(tables, want) {
+		t.Errorf("GetTables = %v, want %v", tables, want)
+	}
+}
@@ -0,0 +1,392 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FixStringEscaping repairs single quotes that were left unescaped inside
+// string literals, such as 'O'Brien', by doubling them. A quote is treated as
+// the end of a literal only when it is followed (ignoring whitespace) by
+// something that can legally follow a value: a comma, a closing parenthesis
+// or bracket (ending an ARRAY[...] constructor), a semicolon, a cast (::), a
+// concatenation (||) or the end of the statement. Where a backslash escapes
+// the next character, in MySQL and in Postgres E'...' strings, an escaped
+// quote such as 'It\'s' is left alone.
+// It returns an error if a literal is never closed.
+func FixStringEscaping(stmt string) (string, error) {
+	var sb strings.Builder
+	inLiteral := false
+	backslashes := false // whether the current literal has backslash escapes
+
+	for i := 0; i < len(stmt); i++ {
+		ch := stmt[i]
+		if inLiteral && backslashes && ch == '\\' && i+1 < len(stmt) {
+			sb.WriteByte(ch)
+			sb.WriteByte(stmt[i+1])
+			i++
+			continue
+		}
+		if ch != '\'' {
+			sb.WriteByte(ch)
+			continue
+		}
+
+		if !inLiteral {
+			inLiteral = true
+			backslashes = ActiveDialect() == MySQL || isEscapeStringPrefix(stmt[:i])
+			sb.WriteByte(ch)
+			continue
+		}
+
+		// Already escaped quote
+		if i+1 < len(stmt) && stmt[i+1] == '\'' {
+			sb.WriteString("''")
+			i++
+			continue
+		}
+
+		if closesLiteral(stmt[i+1:]) {
+			inLiteral = false
+			sb.WriteByte(ch)
+			continue
+		}
+
+		// Stray apostrophe inside the literal
+		sb.WriteString("''")
+	}
+
+	if inLiteral {
+		return "", fmt.Errorf("unterminated string literal in: %s", stmt)
+	}
+	return sb.String(), nil
+}
+
+// isEscapeStringPrefix reports whether before ends with the E of a Postgres
+// escape string such as E'It\'s', rather than a word ending in E.
+func isEscapeStringPrefix(before string) bool {
+	n := len(before)
+	if n == 0 || before[n-1] != 'E' && before[n-1] != 'e' {
+		return false
+	}
+	return n == 1 || !isWordChar(before[n-2])
+}
+
+func closesLiteral(rest string) bool {
+	rest = strings.TrimLeft(rest, " \t\r\n")
+	if rest == "" {
+		return true
+	}
+	switch rest[0] {
+	case ',', ')', ']', ';':
+		return true
+	}
+	return strings.HasPrefix(rest, "::") || strings.HasPrefix(rest, "||")
+}
+
+// SplitStatements splits generated SQL into individual statements. Statements
+// end at a semicolon or at a blank line, since models don't always terminate
+// them consistently; neither counts inside string literals, dollar-quoted
+// bodies, quoted identifiers or parentheses. Like FixStringEscaping, a quote inside a
+// literal only ends it when closesLiteral agrees, so a stray apostrophe such
+// as in 'O'Brien' doesn't swallow the following statements. Empty statements
+// are dropped and the rest are returned trimmed, without semicolons.
+func SplitStatements(sql string) []string {
+	var statements []string
+	add := func(stmt string) {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			statements = append(statements, stmt)
+		}
+	}
+
+	start, depth := 0, 0
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		if quote != 0 {
+			if c != quote {
+				continue
+			}
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+				continue
+			}
+			if quote != '\'' || closesLiteral(sql[i+1:]) {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '$':
+			// A $tag$ body, e.g. of CREATE FUNCTION, runs to the same tag;
+			// a $ after a word character is part of an identifier
+			tag := dollarTag(sql[i:])
+			if tag == "" || i > 0 && isWordChar(sql[i-1]) {
+				continue
+			}
+			if end := strings.Index(sql[i+len(tag):], tag); end >= 0 {
+				i += len(tag) + end + len(tag) - 1
+			} else {
+				i = len(sql)
+			}
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ';':
+			add(sql[start:i])
+			start, depth = i+1, 0
+		case '\n':
+			if depth == 0 && blankLineFollows(sql[i+1:]) {
+				add(sql[start:i])
+				start = i + 1
+			}
+		}
+	}
+	add(sql[start:])
+	return statements
+}
+
+// blankLineFollows reports whether rest, which starts a new line, begins
+// with a line holding only whitespace.
+func blankLineFollows(rest string) bool {
+	line, _, _ := strings.Cut(rest, "\n")
+	return strings.TrimSpace(line) == "" && strings.TrimSpace(rest) != ""
+}
+
+// Insert is a parsed INSERT ... VALUES statement. Identifiers and values are
+// kept exactly as written so String reproduces an equivalent statement.
+type Insert struct {
+	Table   string
+	Columns []string   // empty when the statement has no column list
+	Rows    [][]string // raw value expressions, one slice per VALUES tuple
+	Suffix  string     // anything after the VALUES list, e.g. ON CONFLICT DO NOTHING
+}
+
+// ParseInsert parses a single INSERT INTO ... VALUES statement.
+func ParseInsert(stmt string) (*Insert, error) {
+	s := strings.TrimSpace(stmt)
+	s = strings.TrimSpace(strings.TrimSuffix(s, ";"))
+
+	rest, ok := cutPrefixFold(s, "INSERT")
+	if !ok {
+		return nil, fmt.Errorf("not an INSERT statement")
+	}
+	rest, ok = cutPrefixFold(strings.TrimSpace(rest), "INTO")
+	if !ok {
+		return nil, fmt.Errorf("expected INTO after INSERT")
+	}
+	rest = strings.TrimSpace(rest)
+
+	ins := &Insert{}
+	ins.Table, rest = readIdentifier(rest)
+	if ins.Table == "" {
+		return nil, fmt.Errorf("missing table name")
+	}
+	rest = strings.TrimSpace(rest)
+
+	if strings.HasPrefix(rest, "(") {
+		end := matchingParen(rest)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated column list")
+		}
+		for _, col := range splitTopLevel(rest[1:end]) {
+			ins.Columns = append(ins.Columns, strings.TrimSpace(col))
+		}
+		rest = strings.TrimSpace(rest[end+1:])
+	}
+
+	rest, ok = cutPrefixFold(rest, "VALUES")
+	if !ok {
+		return nil, fmt.Errorf("only INSERT ... VALUES statements are supported")
+	}
+
+	for {
+		rest = strings.TrimSpace(rest)
+		if !strings.HasPrefix(rest, "(") {
+			return nil, fmt.Errorf("expected ( to start a VALUES tuple")
+		}
+		end := matchingParen(rest)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated VALUES tuple")
+		}
+		var row []string
+		for _, v := range splitTopLevel(rest[1:end]) {
+			row = append(row, strings.TrimSpace(v))
+		}
+		ins.Rows = append(ins.Rows, row)
+
+		rest = strings.TrimSpace(rest[end+1:])
+		if !strings.HasPrefix(rest, ",") {
+			break
+		}
+		rest = rest[1:]
+	}
+	ins.Suffix = rest
+
+	return ins, nil
+}
+
+// TableName returns the unquoted table name without any schema qualifier.
+func (ins *Insert) TableName() string {
+	parts := splitOutside(ins.Table, '.')
+	return UnquoteIdentifier(strings.TrimSpace(parts[len(parts)-1]))
+}
+
+// String renders the statement back to SQL, without a trailing semicolon.
+func (ins *Insert) String() string {
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO ")
+	sb.WriteString(ins.Table)
+	if len(ins.Columns) > 0 {
+		sb.WriteString(" (")
+		sb.WriteString(strings.Join(ins.Columns, ", "))
+		sb.WriteString(")")
+	}
+	sb.WriteString(" VALUES ")
+	for i, row := range ins.Rows {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(")
+		sb.WriteString(strings.Join(row, ", "))
+		sb.WriteString(")")
+	}
+	if ins.Suffix != "" {
+		sb.WriteString(" ")
+		sb.WriteString(ins.Suffix)
+	}
+	return sb.String()
+}
+
+// UnquoteIdentifier strips the quotes, double quotes or MySQL backticks,
+// from an identifier as written in SQL.
+func UnquoteIdentifier(name string) string {
+	for _, q := range []string{`"`, "`"} {
+		if len(name) >= 2 && strings.HasPrefix(name, q) && strings.HasSuffix(name, q) {
+			return strings.ReplaceAll(name[1:len(name)-1], q+q, q)
+		}
+	}
+	return name
+}
+
+// QuoteLiteral quotes a string as a SQL string literal in the active
+// dialect.
+func QuoteLiteral(value string) string {
+	return ActiveDialect().QuoteLiteral(value)
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
+
+// readIdentifier reads a possibly quoted and schema-qualified identifier.
+func readIdentifier(s string) (string, string) {
+	i := 0
+	for i < len(s) {
+		switch c := s[i]; {
+		case c == '"' || c == '`':
+			j := i + 1
+			for j < len(s) {
+				if s[j] == c {
+					if j+1 < len(s) && s[j+1] == c {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			i = j + 1
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '(':
+			return s[:i], s[i:]
+		default:
+			i++
+		}
+	}
+	if i > len(s) {
+		i = len(s)
+	}
+	return s[:i], s[i:]
+}
+
+// matchingParen returns the index of the parenthesis closing the one at s[0],
+// skipping over string literals and quoted identifiers, or -1.
+func matchingParen(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote {
+				if i+1 < len(s) && s[i+1] == quote {
+					i++
+					continue
+				}
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits s on commas that are not inside literals, quoted
+// identifiers, parentheses or brackets.
+func splitTopLevel(s string) []string {
+	return splitOutside(s, ',')
+}
+
+// splitOutside splits s on sep wherever it is not inside literals, quoted
+// identifiers, parentheses or brackets.
+func splitOutside(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote {
+				if i+1 < len(s) && s[i+1] == quote {
+					i++
+					continue
+				}
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
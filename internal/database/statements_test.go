@@ -0,0 +1,142 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFixStringEscaping(t *testing.T) {
+	tests := []struct {
+		name string
+		stmt string
+		want string
+	}{
+		{"no quotes", "INSERT INTO t (n) VALUES (1)", "INSERT INTO t (n) VALUES (1)"},
+		{"already escaped", "INSERT INTO t (name) VALUES ('O''Brien')", "INSERT INTO t (name) VALUES ('O''Brien')"},
+		{"apostrophe in a name", "INSERT INTO t (name) VALUES ('O'Brien')", "INSERT INTO t (name) VALUES ('O''Brien')"},
+		{"two apostrophes", "INSERT INTO t (song) VALUES ('rock 'n' roll')", "INSERT INTO t (song) VALUES ('rock ''n'' roll')"},
+		{"apostrophe before a comma", "INSERT INTO t (a, b) VALUES ('it's', 'fine')", "INSERT INTO t (a, b) VALUES ('it''s', 'fine')"},
+		{"several rows", "INSERT INTO t (name) VALUES ('O'Brien'), ('D'Arcy');", "INSERT INTO t (name) VALUES ('O''Brien'), ('D''Arcy');"},
+		{"cast after the literal", "INSERT INTO t (d) VALUES ('2024-01-01'::date)", "INSERT INTO t (d) VALUES ('2024-01-01'::date)"},
+		{"array constructor", "INSERT INTO t (tags) VALUES (ARRAY['kid's', 'adult'])", "INSERT INTO t (tags) VALUES (ARRAY['kid''s', 'adult'])"},
+		{"empty string", "INSERT INTO t (name) VALUES ('')", "INSERT INTO t (name) VALUES ('')"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FixStringEscaping(tt.stmt)
+			if err != nil {
+				t.Fatalf("FixStringEscaping(%q): %v", tt.stmt, err)
+			}
+			if got != tt.want {
+				t.Errorf("FixStringEscaping(%q) = %q, want %q", tt.stmt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFixStringEscapingBackslashes(t *testing.T) {
+	defer SetDialect(ActiveDialect())
+
+	tests := []struct {
+		name    string
+		dialect Dialect
+		stmt    string
+		want    string
+	}{
+		{"mysql escaped quote", MySQL, `INSERT INTO t (s) VALUES ('It\'s')`, `INSERT INTO t (s) VALUES ('It\'s')`},
+		{"mysql escaped backslash before the end", MySQL, `INSERT INTO t (s) VALUES ('C:\\', 'x')`, `INSERT INTO t (s) VALUES ('C:\\', 'x')`},
+		{"mysql stray apostrophe", MySQL, `INSERT INTO t (s) VALUES ('O'Brien')`, `INSERT INTO t (s) VALUES ('O''Brien')`},
+		{"postgres E-string", Postgres, `INSERT INTO t (s) VALUES (E'It\'s')`, `INSERT INTO t (s) VALUES (E'It\'s')`},
+		{"postgres lowercase e-string", Postgres, `INSERT INTO t (s) VALUES (e'It\'s', 'ok')`, `INSERT INTO t (s) VALUES (e'It\'s', 'ok')`},
+		{"postgres plain string keeps backslashes literal", Postgres, `INSERT INTO t (s) VALUES ('C:\', 'x')`, `INSERT INTO t (s) VALUES ('C:\', 'x')`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetDialect(tt.dialect)
+			got, err := FixStringEscaping(tt.stmt)
+			if err != nil {
+				t.Fatalf("FixStringEscaping(%q): %v", tt.stmt, err)
+			}
+			if got != tt.want {
+				t.Errorf("FixStringEscaping(%q) = %q, want %q", tt.stmt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFixStringEscapingUnterminated(t *testing.T) {
+	if _, err := FixStringEscaping("INSERT INTO t (name) VALUES ('O'Brien"); err == nil {
+		t.Error("an unterminated literal was accepted")
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			"semicolons",
+			"INSERT INTO a VALUES (1); INSERT INTO b VALUES (2);",
+			[]string{"INSERT INTO a VALUES (1)", "INSERT INTO b VALUES (2)"},
+		},
+		{
+			"blank lines without semicolons",
+			"INSERT INTO a VALUES (1)\n\nINSERT INTO b VALUES (2)\n  \nINSERT INTO c VALUES (3)",
+			[]string{"INSERT INTO a VALUES (1)", "INSERT INTO b VALUES (2)", "INSERT INTO c VALUES (3)"},
+		},
+		{
+			"single newline continues the statement",
+			"INSERT INTO a\nVALUES (1);",
+			[]string{"INSERT INTO a\nVALUES (1)"},
+		},
+		{
+			"semicolon in a string literal",
+			"INSERT INTO a (s) VALUES ('x; y'); INSERT INTO b VALUES (2)",
+			[]string{"INSERT INTO a (s) VALUES ('x; y')", "INSERT INTO b VALUES (2)"},
+		},
+		{
+			"semicolon in a quoted identifier",
+			`INSERT INTO "a;b" VALUES (1); SELECT 2`,
+			[]string{`INSERT INTO "a;b" VALUES (1)`, "SELECT 2"},
+		},
+		{
+			"stray apostrophe",
+			"INSERT INTO a (s) VALUES ('O'Brien'); INSERT INTO b VALUES (2)",
+			[]string{"INSERT INTO a (s) VALUES ('O'Brien')", "INSERT INTO b VALUES (2)"},
+		},
+		{
+			"dollar-quoted body",
+			"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql; SELECT f();",
+			[]string{"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql", "SELECT f()"},
+		},
+		{
+			"tagged dollar-quoted body",
+			"DO $body$ BEGIN PERFORM 1;\n\nEND $body$; SELECT 2",
+			[]string{"DO $body$ BEGIN PERFORM 1;\n\nEND $body$", "SELECT 2"},
+		},
+		{
+			"placeholders aren't dollar quotes",
+			"SELECT $1; SELECT $2",
+			[]string{"SELECT $1", "SELECT $2"},
+		},
+		{
+			"trailing statement without a terminator",
+			"INSERT INTO a VALUES (1);\nINSERT INTO b VALUES (2)",
+			[]string{"INSERT INTO a VALUES (1)", "INSERT INTO b VALUES (2)"},
+		},
+		{
+			"empty statements",
+			";; \n\n ;",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitStatements(tt.sql); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitStatements(%q) = %q, want %q", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
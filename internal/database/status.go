@@ -0,0 +1,56 @@
+package database
+
+import "context"
+
+// ServerInfo summarises the connected database for status reporting.
+type ServerInfo struct {
+	Dialect  string `json:"dialect"`
+	Version  string `json:"version"`
+	Database string `json:"database"`
+	Tables   int    `json:"tables"`
+	// EstimatedRows is the planner's row estimate summed over all tables,
+	// which is cheap but only as fresh as the last ANALYZE or autovacuum.
+	// It is always 0 for SQLite.
+	EstimatedRows int64 `json:"estimatedRows"`
+}
+
+// GetServerInfo returns the dialect and version of the context's database
+// along with table and row counts for the current schema.
+func GetServerInfo(ctx context.Context) (ServerInfo, error) {
+	info := ServerInfo{Dialect: string(ActiveDialect())}
+	version := "SELECT version(), current_database()"
+	// reltuples is -1 for tables that were never analyzed
+	query := `
+		SELECT count(*), COALESCE(sum(GREATEST(c.reltuples, 0)), 0)::bigint
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p') AND NOT c.relispartition;
+	`
+	switch ActiveDialect() {
+	case MySQL:
+		version = "SELECT version(), DATABASE()"
+		// table_rows is InnoDB's estimate, like reltuples
+		query = `
+			SELECT count(*), COALESCE(sum(table_rows), 0)
+			FROM information_schema.tables
+			WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE';
+		`
+	case SQLite:
+		version = "SELECT sqlite_version(), file FROM pragma_database_list WHERE name = 'main'"
+		// SQLite keeps no row estimates outside of sqlite_stat1, which
+		// only exists once ANALYZE has run, so none are reported
+		query = `
+			SELECT count(*), 0
+			FROM sqlite_master
+			WHERE type = 'table' AND name NOT LIKE 'sqlite\_%' ESCAPE '\';
+		`
+	}
+
+	if err := QueryRowLogged(ctx, version).Scan(&info.Version, &info.Database); err != nil {
+		return ServerInfo{}, err
+	}
+	if err := QueryRowLogged(ctx, query).Scan(&info.Tables, &info.EstimatedRows); err != nil {
+		return ServerInfo{}, err
+	}
+	return info, nil
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Tenants are customers with their own database, selected per request. Their
+// pools are registered at startup; requests without a tenant use DB and
+// ReplicaDB.
+var (
+	tenantsMu   sync.RWMutex
+	tenantPools = make(map[string]*sql.DB)
+)
+
+type tenantKey struct{}
+
+// RegisterTenant opens the pool for a tenant. Like sql.Open it doesn't
+// connect, so an unreachable tenant database doesn't keep the server from
+// starting. Tenant databases must be of the same dialect as the default one,
+// and the search_path passed to InitDB applies to them as well.
+func RegisterTenant(id, connStr string) error {
+	pool, err := openDatabase(connStr)
+	if err != nil {
+		return fmt.Errorf("tenant %s: %v", id, err)
+	}
+
+	tenantsMu.Lock()
+	defer tenantsMu.Unlock()
+	if old, ok := tenantPools[id]; ok {
+		old.Close()
+	}
+	tenantPools[id] = pool
+	return nil
+}
+
+// HasTenant reports whether a tenant is registered.
+func HasTenant(id string) bool {
+	tenantsMu.RLock()
+	defer tenantsMu.RUnlock()
+	_, ok := tenantPools[id]
+	return ok
+}
+
+// Tenants returns the registered tenant IDs in sorted order.
+func Tenants() []string {
+	tenantsMu.RLock()
+	defer tenantsMu.RUnlock()
+	ids := make([]string, 0, len(tenantPools))
+	for id := range tenantPools {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// WithTenant returns a context whose database work goes to the tenant's
+// pool. The tenant must be registered.
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// TenantFromContext returns the tenant set by WithTenant, or "".
+func TenantFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(tenantKey{}).(string)
+	return id
+}
+
+// Primary returns the writable pool for the context's tenant.
+func Primary(ctx context.Context) *sql.DB {
+	id := TenantFromContext(ctx)
+	if id == "" {
+		return DB
+	}
+	tenantsMu.RLock()
+	defer tenantsMu.RUnlock()
+	pool, ok := tenantPools[id]
+	if !ok {
+		// Never fall back to the default database: that would serve
+		// another customer's data
+		panic("database: unregistered tenant " + id)
+	}
+	return pool
+}
+
+// Reader returns the pool for read-only work for the context's tenant: the
+// replica when one is configured, otherwise the primary. Tenants have no
+// replicas.
+func Reader(ctx context.Context) *sql.DB {
+	if TenantFromContext(ctx) == "" && ReplicaDB != nil {
+		return ReplicaDB
+	}
+	return Primary(ctx)
+}
+
+func closeTenants() {
+	tenantsMu.Lock()
+	defer tenantsMu.Unlock()
+	for id, pool := range tenantPools {
+		pool.Close()
+		delete(tenantPools, id)
+	}
+}
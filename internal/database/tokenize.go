@@ -0,0 +1,167 @@
+package database
+
+import "strings"
+
+type tokenKind int
+
+const (
+	tokenWord       tokenKind = iota // unquoted identifier or keyword
+	tokenIdentifier                  // "quoted identifier" or `quoted identifier`
+	tokenString                      // 'literal', E'literal' or $$literal$$
+	tokenNumber
+	tokenPunct // any other single character
+)
+
+type token struct {
+	kind tokenKind
+	text string // words are uppercased, quoted identifiers unquoted
+	pos  int    // offset of the token in the SQL
+}
+
+// tokenize splits SQL into tokens, dropping whitespace and comments. It is
+// deliberately lenient: it only needs to be good enough to find keywords and
+// table references, never to validate syntax. In MySQL a backslash escapes
+// the next character of any string literal and # starts a comment, so the
+// active dialect is followed there.
+func tokenize(sql string) []token {
+	mysql := ActiveDialect() == MySQL
+	var tokens []token
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-', c == '#' && mysql:
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 4
+			}
+		case c == '\'':
+			end := quotedEnd(sql, i, '\'')
+			if mysql {
+				end = escapeStringEnd(sql, i)
+			}
+			tokens = append(tokens, token{tokenString, sql[i+1 : max(i+1, end-1)], i})
+			i = end
+		case c == '"' || c == '`':
+			end := quotedEnd(sql, i, c)
+			name := strings.ReplaceAll(sql[i+1:max(i+1, end-1)], string(c)+string(c), string(c))
+			tokens = append(tokens, token{tokenIdentifier, name, i})
+			i = end
+		case c == '$' && dollarTag(sql[i:]) != "":
+			tag := dollarTag(sql[i:])
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				tokens = append(tokens, token{tokenString, sql[i+len(tag):], i})
+				i = len(sql)
+			} else {
+				tokens = append(tokens, token{tokenString, sql[i+len(tag) : i+len(tag)+end], i})
+				i += len(tag) + end + len(tag)
+			}
+		case isWordStart(c):
+			j := i + 1
+			for j < len(sql) && isWordChar(sql[j]) {
+				j++
+			}
+			// E'...' escape string literal, in which a backslash escapes
+			// the next character, quotes included
+			if j == i+1 && (c == 'E' || c == 'e') && j < len(sql) && sql[j] == '\'' {
+				end := escapeStringEnd(sql, j)
+				tokens = append(tokens, token{tokenString, sql[j+1 : max(j+1, end-1)], j})
+				i = end
+				continue
+			}
+			tokens = append(tokens, token{tokenWord, strings.ToUpper(sql[i:j]), i})
+			i = j
+		case c >= '0' && c <= '9':
+			j := i + 1
+			for j < len(sql) && (sql[j] >= '0' && sql[j] <= '9' || sql[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokenNumber, sql[i:j], i})
+			i = j
+		default:
+			tokens = append(tokens, token{tokenPunct, string(c), i})
+			i++
+		}
+	}
+	return tokens
+}
+
+// quotedEnd returns the index just past the quote closing the one at s[start],
+// treating doubled quotes as escapes.
+func quotedEnd(s string, start int, quote byte) int {
+	for i := start + 1; i < len(s); i++ {
+		if s[i] == quote {
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// escapeStringEnd is quotedEnd for the E'...' string, or MySQL string,
+// whose quote is at s[start], where a backslash also escapes the character
+// after it.
+func escapeStringEnd(s string, start int) int {
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '\'':
+			if i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// dollarTag returns the $tag$ opening a dollar-quoted string at the start of
+// s, or "" if there is none.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '$' {
+			return s[:i+1]
+		}
+		if !isWordChar(s[i]) || (i == 1 && s[i] >= '0' && s[i] <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isWordChar(c byte) bool {
+	return isWordStart(c) || (c >= '0' && c <= '9') || c == '$'
+}
+
+func (t token) isWord(words ...string) bool {
+	if t.kind != tokenWord {
+		return false
+	}
+	for _, w := range words {
+		if t.text == w {
+			return true
+		}
+	}
+	return false
+}
+
+func (t token) isPunct(p string) bool {
+	return t.kind == tokenPunct && t.text == p
+}
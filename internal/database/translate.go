@@ -0,0 +1,192 @@
+package database
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// TranslateInsert rewrites a generated PostgreSQL INSERT for another dialect:
+// identifiers are quoted the dialect's way, casts are dropped, escape
+// strings and arrays become plain literals, and booleans and common
+// functions take the dialect's spelling. Statements that don't parse as
+// INSERT ... VALUES are returned unchanged.
+func TranslateInsert(stmt string, d Dialect) string {
+	if d == Postgres {
+		return stmt
+	}
+	ins, err := ParseInsert(stmt)
+	if err != nil {
+		return stmt
+	}
+
+	parts := splitOutside(ins.Table, '.')
+	for i, part := range parts {
+		parts[i] = d.QuoteIdentifier(UnquoteIdentifier(strings.TrimSpace(part)))
+	}
+	ins.Table = strings.Join(parts, ".")
+	for i, col := range ins.Columns {
+		ins.Columns[i] = d.QuoteIdentifier(UnquoteIdentifier(strings.TrimSpace(col)))
+	}
+	for _, row := range ins.Rows {
+		for i, value := range row {
+			row[i] = translateValue(strings.TrimSpace(value), d)
+		}
+	}
+
+	// MySQL has no ON CONFLICT, but INSERT IGNORE skips conflicting rows
+	if d == MySQL && strings.EqualFold(strings.Join(strings.Fields(ins.Suffix), " "), "ON CONFLICT DO NOTHING") {
+		ins.Suffix = ""
+		return "INSERT IGNORE" + strings.TrimPrefix(ins.String(), "INSERT")
+	}
+	return ins.String()
+}
+
+// translateValue rewrites one VALUES expression for d.
+func translateValue(expr string, d Dialect) string {
+	expr = stripCasts(expr)
+	upper := strings.ToUpper(expr)
+
+	switch {
+	case upper == "TRUE" || upper == "FALSE":
+		if d == SQLite {
+			if upper == "TRUE" {
+				return "1"
+			}
+			return "0"
+		}
+		return upper
+	case upper == "NOW()" && d == SQLite:
+		return "CURRENT_TIMESTAMP"
+	case upper == "GEN_RANDOM_UUID()":
+		if d == MySQL {
+			return "UUID()"
+		}
+		return "lower(hex(randomblob(16)))"
+	case strings.HasPrefix(upper, "ARRAY[") && strings.HasSuffix(expr, "]"):
+		if literal, ok := arrayToJSON(expr[len("ARRAY[") : len(expr)-1]); ok {
+			return d.QuoteLiteral(literal)
+		}
+		return expr
+	}
+
+	if value, ok := literalValue(expr); ok {
+		return d.QuoteLiteral(value)
+	}
+	return expr
+}
+
+// stripCasts drops trailing PostgreSQL ::type casts, which other dialects
+// don't accept; the literal is converted on insert instead.
+func stripCasts(expr string) string {
+	for {
+		parts := splitOutsideString(expr, "::")
+		if len(parts) < 2 {
+			return expr
+		}
+		expr = strings.TrimSpace(strings.Join(parts[:len(parts)-1], "::"))
+	}
+}
+
+// splitOutsideString splits s on sep wherever it is outside string literals
+// and quoted identifiers.
+func splitOutsideString(s, sep string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// literalValue returns the value of a '...' or E'...' string literal.
+func literalValue(expr string) (string, bool) {
+	escaped := false
+	if len(expr) > 0 && (expr[0] == 'E' || expr[0] == 'e') {
+		escaped = true
+		expr = expr[1:]
+	}
+	if len(expr) < 2 || expr[0] != '\'' || expr[len(expr)-1] != '\'' {
+		return "", false
+	}
+	body := expr[1 : len(expr)-1]
+	if !escaped {
+		return strings.ReplaceAll(body, "''", "'"), true
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == '\'' && i+1 < len(body) && body[i+1] == '\'':
+			sb.WriteByte('\'')
+			i++
+		case c == '\\' && i+1 < len(body):
+			i++
+			switch body[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			default:
+				sb.WriteByte(body[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), true
+}
+
+// arrayToJSON renders the elements of an ARRAY[...] constructor as a JSON
+// array, the usual stand-in for arrays in dialects without them. It fails
+// for elements other than literals and NULL.
+func arrayToJSON(elems string) (string, bool) {
+	values := []interface{}{}
+	for _, elem := range splitTopLevel(elems) {
+		elem = stripCasts(strings.TrimSpace(elem))
+		if elem == "" {
+			continue
+		}
+		if s, ok := literalValue(elem); ok {
+			values = append(values, s)
+			continue
+		}
+		switch strings.ToUpper(elem) {
+		case "NULL":
+			values = append(values, nil)
+		case "TRUE":
+			values = append(values, true)
+		case "FALSE":
+			values = append(values, false)
+		default:
+			n, err := strconv.ParseFloat(elem, 64)
+			if err != nil {
+				return "", false
+			}
+			values = append(values, n)
+		}
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
@@ -0,0 +1,68 @@
+package database
+
+import "testing"
+
+func TestTranslateInsert(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		stmt    string
+		want    string
+	}{
+		{
+			"postgres is unchanged",
+			Postgres,
+			`INSERT INTO "users" (id, active) VALUES (1, TRUE);`,
+			`INSERT INTO "users" (id, active) VALUES (1, TRUE);`,
+		},
+		{
+			"mysql quotes with backticks",
+			MySQL,
+			`INSERT INTO "order" ("user", total) VALUES (1, 9.5)`,
+			"INSERT INTO `order` (`user`, `total`) VALUES (1, 9.5)",
+		},
+		{
+			"sqlite keeps double quotes",
+			SQLite,
+			`INSERT INTO "order" ("user", total) VALUES (1, 9.5)`,
+			`INSERT INTO "order" ("user", "total") VALUES (1, 9.5)`,
+		},
+		{
+			"casts are dropped",
+			MySQL,
+			`INSERT INTO events (at) VALUES ('2024-01-01'::timestamp)`,
+			"INSERT INTO `events` (`at`) VALUES ('2024-01-01')",
+		},
+		{
+			"sqlite booleans become integers",
+			SQLite,
+			`INSERT INTO users (active, admin) VALUES (TRUE, false)`,
+			`INSERT INTO "users" ("active", "admin") VALUES (1, 0)`,
+		},
+		{
+			"mysql doubles backslashes from escape strings",
+			MySQL,
+			`INSERT INTO notes (body) VALUES (E'a\\b')`,
+			"INSERT INTO `notes` (`body`) VALUES ('a\\\\b')",
+		},
+		{
+			"arrays become JSON",
+			SQLite,
+			`INSERT INTO posts (tags) VALUES (ARRAY['go', 'sql'])`,
+			`INSERT INTO "posts" ("tags") VALUES ('["go","sql"]')`,
+		},
+		{
+			"mysql turns ON CONFLICT DO NOTHING into INSERT IGNORE",
+			MySQL,
+			`INSERT INTO users (id) VALUES (1) ON CONFLICT DO NOTHING`,
+			"INSERT IGNORE INTO `users` (`id`) VALUES (1)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TranslateInsert(tt.stmt, tt.dialect); got != tt.want {
+				t.Errorf("TranslateInsert(%q, %s) =\n%s\nwant\n%s", tt.stmt, tt.dialect, got, tt.want)
+			}
+		})
+	}
+}
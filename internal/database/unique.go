@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"strings"
+)
+
+// GetUniqueColumns returns, for each table in the current schema, the
+// columns that are unique on their own: single-column primary keys and
+// unique constraints.
+func GetUniqueColumns(ctx context.Context) (map[string][]string, error) {
+	query := `
+		SELECT tc.table_name, kcu.column_name
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON kcu.constraint_schema = tc.constraint_schema AND kcu.constraint_name = tc.constraint_name
+		WHERE tc.table_schema = current_schema()
+			AND tc.constraint_type IN ('PRIMARY KEY', 'UNIQUE')
+			AND (SELECT count(*) FROM information_schema.key_column_usage k
+				WHERE k.constraint_schema = tc.constraint_schema AND k.constraint_name = tc.constraint_name) = 1
+		ORDER BY tc.table_name, kcu.column_name;
+	`
+	switch ActiveDialect() {
+	case MySQL:
+		// Every primary key is named PRIMARY, so constraints are only
+		// unique together with their table
+		query = `
+			SELECT tc.table_name, kcu.column_name
+			FROM information_schema.table_constraints tc
+			JOIN information_schema.key_column_usage kcu
+				ON kcu.constraint_schema = tc.constraint_schema AND kcu.table_name = tc.table_name
+					AND kcu.constraint_name = tc.constraint_name
+			WHERE tc.table_schema = DATABASE()
+				AND tc.constraint_type IN ('PRIMARY KEY', 'UNIQUE')
+				AND (SELECT count(*) FROM information_schema.key_column_usage k
+					WHERE k.constraint_schema = tc.constraint_schema AND k.table_name = tc.table_name
+						AND k.constraint_name = tc.constraint_name) = 1
+			ORDER BY tc.table_name, kcu.column_name;
+		`
+	case SQLite:
+		// Unique constraints and primary keys are backed by unique
+		// indexes, except for an INTEGER PRIMARY KEY, which is the rowid
+		query = `
+			SELECT m.name, ii.name
+			FROM sqlite_master m
+			JOIN pragma_index_list(m.name) il
+			JOIN pragma_index_info(il.name) ii
+			WHERE m.type = 'table' AND il."unique" AND (SELECT count(*) FROM pragma_index_info(il.name)) = 1
+			UNION
+			SELECT m.name, p.name
+			FROM sqlite_master m
+			JOIN pragma_table_info(m.name) p
+			WHERE m.type = 'table' AND p.pk = 1 AND (SELECT count(*) FROM pragma_table_info(m.name) WHERE pk > 0) = 1
+			ORDER BY 1, 2;
+		`
+	}
+	rows, err := QueryLogged(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	uniques := make(map[string][]string)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		uniques[table] = append(uniques[table], column)
+	}
+	return uniques, rows.Err()
+}
+
+// DetectDuplicateInserts returns the indexes of the INSERT statements that
+// would violate a unique column, given as uniques by table, because they
+// repeat a value from an earlier statement of the batch or from another row
+// of their own VALUES list. A statement that is reported doesn't count as
+// having used its values, so removing every reported statement leaves a
+// batch without duplicates. NULL and DEFAULT never collide; statements that
+// don't parse or have no column list are ignored.
+func DetectDuplicateInserts(statements []string, uniques map[string][]string) []int {
+	seen := make(map[string]bool)
+	var duplicates []int
+
+	for i, stmt := range statements {
+		ins, err := ParseInsert(stmt)
+		if err != nil || len(ins.Columns) == 0 {
+			continue
+		}
+		table := ins.TableName()
+		columns, ok := uniques[table]
+		if !ok {
+			columns = uniques[strings.ToLower(table)]
+		}
+
+		var keys []string
+		own := make(map[string]bool)
+		duplicate := false
+		for _, column := range columns {
+			pos := -1
+			for j, c := range ins.Columns {
+				if strings.EqualFold(UnquoteIdentifier(c), column) {
+					pos = j
+					break
+				}
+			}
+			if pos < 0 {
+				continue
+			}
+			for _, row := range ins.Rows {
+				if pos >= len(row) {
+					continue
+				}
+				value := strings.TrimSpace(row[pos])
+				if strings.EqualFold(value, "NULL") || strings.EqualFold(value, "DEFAULT") {
+					continue
+				}
+				key := table + "." + column + "\x00" + value
+				if seen[key] || own[key] {
+					duplicate = true
+				}
+				own[key] = true
+				keys = append(keys, key)
+			}
+		}
+
+		if duplicate {
+			duplicates = append(duplicates, i)
+			continue
+		}
+		for _, key := range keys {
+			seen[key] = true
+		}
+	}
+	return duplicates
+}
@@ -0,0 +1,14 @@
+package database
+
+// ConvertValue maps a value scanned into an interface{} to the form it
+// should take in JSON. lib/pq returns text, numeric and other types it
+// doesn't decode as []byte holding their text form, which encoding/json
+// would write as base64, so those become strings. Integers, floats and
+// booleans are kept so they encode as JSON numbers and booleans, and
+// time.Time as an RFC 3339 string.
+func ConvertValue(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
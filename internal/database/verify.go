@@ -0,0 +1,26 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VerificationQuery builds the default post-generation check for a table.
+// Like user-provided checks it selects problems, one row each, so it passes
+// when it returns no rows. It reports an empty table and nullable columns
+// that never got a value.
+func VerificationQuery(table Table) string {
+	name := QuoteIdentifier(table.Name)
+	checks := []string{
+		fmt.Sprintf("SELECT 'table is empty' AS problem WHERE NOT EXISTS (SELECT 1 FROM %s)", name),
+	}
+	for _, col := range table.Columns {
+		if !col.Nullable || col.Generated {
+			continue
+		}
+		checks = append(checks, fmt.Sprintf(
+			"SELECT %s AS problem WHERE EXISTS (SELECT 1 FROM %s) AND NOT EXISTS (SELECT 1 FROM %s WHERE %s IS NOT NULL)",
+			QuoteLiteral("column "+col.Name+" is always NULL"), name, name, QuoteIdentifier(col.Name)))
+	}
+	return strings.Join(checks, "\nUNION ALL\n")
+}
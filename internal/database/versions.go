@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Table versions let caches of query results detect that the underlying data
+// changed. Each write path bumps the tables it touched; schema changes bump
+// the epoch, which invalidates everything. Versions are kept per tenant.
+var (
+	versionsMu    sync.Mutex
+	versionEpochs = make(map[string]uint64)            // by tenant
+	tableVersions = make(map[string]map[string]uint64) // by tenant, then table
+)
+
+// BumpTableVersions records that the given tables of the context's tenant
+// were modified.
+func BumpTableVersions(ctx context.Context, names ...string) {
+	tenant := TenantFromContext(ctx)
+	versionsMu.Lock()
+	defer versionsMu.Unlock()
+	if tableVersions[tenant] == nil {
+		tableVersions[tenant] = make(map[string]uint64)
+	}
+	for _, name := range names {
+		tableVersions[tenant][strings.ToLower(name)]++
+	}
+}
+
+// BumpSchemaVersion records a change that may affect any table of the
+// context's tenant.
+func BumpSchemaVersion(ctx context.Context) {
+	versionsMu.Lock()
+	versionEpochs[TenantFromContext(ctx)]++
+	versionsMu.Unlock()
+}
+
+// VersionKey returns a string identifying the tenant and the current version
+// of the given tables, for use in cache keys.
+func VersionKey(ctx context.Context, names []string) string {
+	tenant := TenantFromContext(ctx)
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+
+	versionsMu.Lock()
+	defer versionsMu.Unlock()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "tenant=%q;epoch=%d", tenant, versionEpochs[tenant])
+	for _, name := range sorted {
+		fmt.Fprintf(&sb, ";%s=%d", name, tableVersions[tenant][strings.ToLower(name)])
+	}
+	return sb.String()
+}
@@ -0,0 +1,136 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Errors returned when a call doesn't fit the token budget.
+var (
+	ErrOverRequestBudget    = errors.New("the request would exceed the per-request token limit")
+	ErrDailyBudgetExhausted = errors.New("the daily token budget is exhausted")
+)
+
+// defaultOutputEstimate stands in for the output of calls that don't set a
+// maximum, when estimating their cost.
+const defaultOutputEstimate = 8192
+
+// TokenBudget caps the tokens spent per request and per UTC day. Usage is
+// taken from the responses' usage metadata and kept in memory, so it starts
+// over when the server restarts. A zero limit is unlimited. It is shared by
+// every client, so rotating the API key doesn't reset it.
+type TokenBudget struct {
+	perRequest int64
+	daily      int64
+
+	mu   sync.Mutex
+	day  string // UTC date that used counts toward
+	used int64
+}
+
+func NewTokenBudget(perRequest, daily int64) *TokenBudget {
+	return &TokenBudget{perRequest: perRequest, daily: daily}
+}
+
+// Limited reports whether any limit is set, and so whether calls need their
+// prompts counted first.
+func (b *TokenBudget) Limited() bool {
+	return b != nil && (b.perRequest > 0 || b.daily > 0)
+}
+
+// Reservation is the share of the daily budget that Check set aside for a
+// call, until Record replaces it with what the call used.
+type Reservation struct {
+	day    string
+	tokens int64
+}
+
+// Check reports whether a call estimated at estimate tokens fits the limits
+// and, when it does, reserves the estimate against the daily limit, so
+// concurrent calls can't all pass on the same remaining tokens. Every
+// successful Check must be followed by a Record, even if the call fails.
+func (b *TokenBudget) Check(estimate int64) (Reservation, error) {
+	if b == nil {
+		return Reservation{}, nil
+	}
+	if b.perRequest > 0 && estimate > b.perRequest {
+		return Reservation{}, fmt.Errorf("%w (about %d tokens, limit %d)", ErrOverRequestBudget, estimate, b.perRequest)
+	}
+	if b.daily <= 0 {
+		return Reservation{}, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+	if b.used+estimate > b.daily {
+		return Reservation{}, fmt.Errorf("%w (%d of %d tokens used today)", ErrDailyBudgetExhausted, b.used, b.daily)
+	}
+	b.used += estimate
+	return Reservation{day: b.day, tokens: estimate}, nil
+}
+
+// Record replaces a call's reservation with the tokens it actually used,
+// zero for a call that failed. A reservation from a day that has since
+// ended no longer counts, so only the usage is added.
+func (b *TokenBudget) Record(r Reservation, tokens int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+	if r.day == b.day {
+		b.used -= r.tokens
+	}
+	b.used += tokens
+}
+
+// UsedToday returns the tokens used since midnight UTC.
+func (b *TokenBudget) UsedToday() int64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+	return b.used
+}
+
+// rollover starts a new day's count; b.mu must be held.
+func (b *TokenBudget) rollover() {
+	if today := time.Now().UTC().Format("2006-01-02"); today != b.day {
+		b.day, b.used = today, 0
+	}
+}
+
+// reserve checks that a call sending system and prompt and producing up to
+// maxOutput tokens fits the budget, and reserves it. The prompt is only
+// counted when a limit is set, as counting is itself an API call.
+func (c *Client) reserve(ctx context.Context, system, prompt string, maxOutput int32) (Reservation, error) {
+	if !c.budget.Limited() {
+		return Reservation{}, nil
+	}
+	if maxOutput <= 0 {
+		maxOutput = defaultOutputEstimate
+	}
+	tokens, err := c.CountTokens(ctx, system+"\n"+prompt)
+	if err != nil {
+		return Reservation{}, err
+	}
+	return c.budget.Check(int64(tokens) + int64(maxOutput))
+}
+
+// recordUsage charges a response's tokens to the budget in place of the
+// call's reservation. resp is nil when the call failed.
+func (c *Client) recordUsage(r Reservation, resp *genai.GenerateContentResponse) {
+	var tokens int64
+	if resp != nil && resp.UsageMetadata != nil {
+		tokens = int64(resp.UsageMetadata.TotalTokenCount)
+	}
+	c.budget.Record(r, tokens)
+}
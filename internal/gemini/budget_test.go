@@ -0,0 +1,106 @@
+package gemini
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTokenBudgetPerRequestLimit(t *testing.T) {
+	b := NewTokenBudget(100, 1000)
+	if _, err := b.Check(101); !errors.Is(err, ErrOverRequestBudget) {
+		t.Errorf("Check(101) = %v, want ErrOverRequestBudget", err)
+	}
+	if used := b.UsedToday(); used != 0 {
+		t.Errorf("a rejected call reserved %d tokens", used)
+	}
+	if _, err := b.Check(100); err != nil {
+		t.Errorf("Check(100) = %v, want nil", err)
+	}
+}
+
+func TestTokenBudgetReservesUntilRecorded(t *testing.T) {
+	b := NewTokenBudget(0, 100)
+
+	r, err := b.Check(60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Check(60); !errors.Is(err, ErrDailyBudgetExhausted) {
+		t.Errorf("second Check(60) = %v, want ErrDailyBudgetExhausted while the first is reserved", err)
+	}
+
+	// The reservation is replaced by the actual usage
+	b.Record(r, 30)
+	if used := b.UsedToday(); used != 30 {
+		t.Errorf("UsedToday = %d after recording 30 tokens, want 30", used)
+	}
+
+	// A failed call releases its reservation
+	r, err = b.Check(60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Record(r, 0)
+	if used := b.UsedToday(); used != 30 {
+		t.Errorf("UsedToday = %d after a failed call, want 30", used)
+	}
+}
+
+func TestTokenBudgetConcurrentChecks(t *testing.T) {
+	b := NewTokenBudget(0, 100)
+	var passed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := b.Check(20); err == nil {
+				passed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := passed.Load(); n != 5 {
+		t.Errorf("%d calls of 20 tokens passed a daily limit of 100, want 5", n)
+	}
+}
+
+func TestTokenBudgetRollover(t *testing.T) {
+	b := NewTokenBudget(0, 100)
+	r, err := b.Check(90)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The day ends while the call runs
+	b.mu.Lock()
+	b.day = "2000-01-01"
+	r.day = b.day
+	b.mu.Unlock()
+
+	if used := b.UsedToday(); used != 0 {
+		t.Errorf("UsedToday = %d on a new day, want 0", used)
+	}
+	if _, err := b.Check(90); err != nil {
+		t.Errorf("Check(90) on a new day = %v, want nil", err)
+	}
+	// Yesterday's reservation isn't taken off today's count
+	b.Record(r, 5)
+	if used := b.UsedToday(); used != 95 {
+		t.Errorf("UsedToday = %d, want today's 90 reserved plus 5 used", used)
+	}
+}
+
+func TestTokenBudgetNil(t *testing.T) {
+	var b *TokenBudget
+	r, err := b.Check(1 << 40)
+	if err != nil {
+		t.Errorf("nil budget Check = %v, want nil", err)
+	}
+	b.Record(r, 10)
+	if b.Limited() || b.UsedToday() != 0 {
+		t.Error("nil budget reports a limit or usage")
+	}
+}
@@ -0,0 +1,53 @@
+package gemini
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultChartKeywords are the words that mark a question as asking for a
+// chart, in the languages users most often write in. CHART_KEYWORDS adds to
+// them.
+var DefaultChartKeywords = []string{
+	// English
+	"chart", "graph", "plot", "show", "draw", "visualize",
+	// Spanish
+	"gráfico", "gráfica", "diagrama", "dibuja", "muestra", "visualiza",
+	// Portuguese
+	"gráfico", "grafo", "mostre", "desenhe", "visualize",
+	// French
+	"graphique", "diagramme", "courbe", "affiche", "dessine", "visualiser",
+	// German
+	"diagramm", "grafik", "zeige", "zeichne", "visualisiere",
+	// Italian
+	"grafico", "diagramma", "mostra", "disegna", "visualizza",
+}
+
+// ChartKeywords returns the default keywords plus extra, without repeats.
+func ChartKeywords(extra []string) []string {
+	seen := make(map[string]bool)
+	var keywords []string
+	for _, kw := range append(DefaultChartKeywords, extra...) {
+		kw = strings.ToLower(strings.TrimSpace(kw))
+		if kw != "" && !seen[kw] {
+			seen[kw] = true
+			keywords = append(keywords, kw)
+		}
+	}
+	return keywords
+}
+
+// chartMarker matches the -- CHART: comment that ends a chart query. It is
+// lenient about case and spacing, as models don't always copy it exactly.
+var chartMarker = regexp.MustCompile(`(?i)--\s*chart\s*:\s*([a-z]*)`)
+
+// ParseChartMarker splits the -- CHART: comment off generated SQL. It
+// returns the SQL without the comment, the lowercased chart type, and
+// whether the comment was present.
+func ParseChartMarker(text string) (sql, chartType string, found bool) {
+	loc := chartMarker.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return strings.TrimSpace(text), "", false
+	}
+	return strings.TrimSpace(text[:loc[0]]), strings.ToLower(text[loc[2]:loc[3]]), true
+}
@@ -2,8 +2,17 @@ package gemini
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+
+	"genai/internal/config"
 
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
@@ -11,94 +20,736 @@ import (
 
 type Client struct {
 	genaiClient *genai.Client
-	model       *genai.GenerativeModel
+	modelName   string
+
+	explainMu    sync.Mutex
+	explanations map[string]string // keyed by SQL hash
+
+	schemas       *schemaCache
+	budget        *TokenBudget
+	chartKeywords []string // added to DefaultChartKeywords
+	dialect       string   // the database's SQL dialect, as in DATABASE_DIALECT
+	queryTemp     float32
 }
 
-func NewClient(apiKey, modelName string) (*Client, error) {
+// maxCachedExplanations bounds the ExplainSQL cache; it is reset once full.
+const maxCachedExplanations = 500
+
+// NewClient creates a client for cfg's key and model whose calls are charged
+// to budget, which may be nil for no limits.
+func NewClient(cfg *config.Config, budget *TokenBudget) (*Client, error) {
 	ctx := context.Background()
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	client, err := genai.NewClient(ctx, option.WithAPIKey(cfg.GeminiKey))
 	if err != nil {
 		return nil, err
 	}
 
-	if modelName == "" {
-		modelName = "gemini-2.0-flash"
-	}
-	model := client.GenerativeModel(modelName)
 	return &Client{
-		genaiClient: client,
-		model:       model,
+		genaiClient:   client,
+		modelName:     cfg.GeminiModel,
+		explanations:  make(map[string]string),
+		schemas:       newSchemaCache(cfg.GeminiCacheTTL),
+		budget:        budget,
+		chartKeywords: cfg.ChartKeywords,
+		dialect:       cfg.DatabaseDialect,
+		queryTemp:     cfg.QueryTemperature,
 	}, nil
 }
 
 func (c *Client) Close() {
+	c.schemas.release(c.genaiClient)
 	c.genaiClient.Close()
 }
 
-// GenerateDataSQL asks Gemini to generate INSERT statements based on the schema
-func (c *Client) GenerateDataSQL(ctx context.Context, schema string, temperature float32, maxTokens int) (string, error) {
-	c.model.SetTemperature(temperature)
-	c.model.SetMaxOutputTokens(int32(maxTokens))
+// GenerateOptions controls how GenerateDataSQL produces data.
+type GenerateOptions struct {
+	Temperature float32
+	MaxTokens   int
+
+	// Dialect is the SQL dialect to write the statements in, as in
+	// DATABASE_DIALECT; empty means postgres.
+	Dialect string
+
+	// Preset names a Preset; its top-p and instruction are applied, while
+	// Temperature is left to the caller so it can be overridden.
+	Preset string
+
+	// ReferentialDensity maps a foreign key column, written as "table.column",
+	// to the fraction (0-1) of parent rows that should be referenced by at
+	// least one child row.
+	ReferentialDensity map[string]float64
+
+	// NumericRanges maps a numeric column, written as "table.column", to the
+	// inclusive range its values must fall in.
+	NumericRanges map[string]NumericRange
+
+	// NullRates maps a nullable column, written as "table.column", to the
+	// fraction (0-1) of rows that should leave it NULL.
+	NullRates map[string]float64
+
+	// KeyStarts maps an integer key column, written as "table.column", to
+	// the first value to use, so appended rows don't collide with existing
+	// ones.
+	KeyStarts map[string]int64
+
+	// RowTargets maps a table to how many rows to insert into it, replacing
+	// the default statement count.
+	RowTargets map[string]int
+
+	// RowsPerTable, when set, is how many rows to insert into every table,
+	// replacing the default statement count.
+	RowsPerTable int
+
+	// PriorRows is how many rows per table earlier batches of the same
+	// generation inserted, when it is split across several calls.
+	PriorRows int
+
+	// LocalColumns lists columns, written as "table.column", whose values
+	// are generated locally and added afterwards, so the model leaves them
+	// out.
+	LocalColumns []string
+
+	// LocalTables lists tables whose rows are generated locally after the
+	// model's, such as many-to-many join tables, so the model skips them.
+	LocalTables []string
+
+	// TimeRange, when set, bounds every date and timestamp column.
+	TimeRange *TimeRange
+
+	// AllowedValues maps a column, written as "table.column", to the only
+	// values it may take, e.g. the existing keys of a foreign key's parent.
+	AllowedValues map[string][]string
+
+	// SafeText keeps delimiters, quotes and control characters out of
+	// generated text values.
+	SafeText bool
+
+	// SafeContent asks for values fit for a corporate demo: no profanity,
+	// offensive content or real people's personal data.
+	SafeContent bool
+
+	// StopSequences, TopK and CandidateCount are passed through to the
+	// model's generation config; zero values keep the model defaults. With
+	// several candidates the first one that finished normally is used.
+	StopSequences  []string
+	TopK           int
+	CandidateCount int
+
+	// ThinkingBudget is how many tokens the model may spend reasoning before
+	// it answers; zero keeps the model default. No provider supports it yet,
+	// so CheckOptions rejects any other value.
+	ThinkingBudget int
+}
+
+// Limits enforced by the Gemini API.
+const (
+	maxStopSequences = 5
+	maxCandidates    = 8
+)
+
+// TimeRange is an inclusive range for generated dates and timestamps. Start
+// and End are dates (2006-01-02) or RFC 3339 timestamps. With BusinessHours,
+// values cluster on weekdays during working hours, as orders and events do,
+// instead of spreading evenly around the clock.
+type TimeRange struct {
+	Start         string `json:"start"`
+	End           string `json:"end"`
+	BusinessHours bool   `json:"businessHours"`
+}
+
+// Bounds parses the start and end of the range.
+func (r TimeRange) Bounds() (start, end time.Time, err error) {
+	if start, err = parseTimeBound(r.Start); err != nil {
+		return start, end, fmt.Errorf("timeRange start must be a date or RFC 3339 timestamp")
+	}
+	if end, err = parseTimeBound(r.End); err != nil {
+		return start, end, fmt.Errorf("timeRange end must be a date or RFC 3339 timestamp")
+	}
+	return start, end, nil
+}
+
+// parseTimeBound parses a TimeRange bound in either accepted layout.
+func parseTimeBound(v string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// NumericRange is an inclusive range for generated numeric values.
+type NumericRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// Validate reports the first invalid option, if any.
+func (o GenerateOptions) Validate() error {
+	if _, err := LookupPreset(o.Preset); err != nil {
+		return err
+	}
+	for fk, density := range o.ReferentialDensity {
+		if !strings.Contains(fk, ".") {
+			return fmt.Errorf("referentialDensity key %q must be in table.column form", fk)
+		}
+		if density < 0 || density > 1 {
+			return fmt.Errorf("referentialDensity for %s must be between 0 and 1", fk)
+		}
+	}
+	for col, rate := range o.NullRates {
+		if !strings.Contains(col, ".") {
+			return fmt.Errorf("nullRates key %q must be in table.column form", col)
+		}
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("nullRates for %s must be between 0 and 1", col)
+		}
+	}
+	if len(o.StopSequences) > maxStopSequences {
+		return fmt.Errorf("at most %d stopSequences are allowed", maxStopSequences)
+	}
+	for _, seq := range o.StopSequences {
+		if seq == "" {
+			return fmt.Errorf("stopSequences must not contain empty strings")
+		}
+	}
+	if o.TopK < 0 {
+		return fmt.Errorf("topK must not be negative")
+	}
+	if o.CandidateCount < 0 || o.CandidateCount > maxCandidates {
+		return fmt.Errorf("candidateCount must be between 1 and %d", maxCandidates)
+	}
+	if o.ThinkingBudget < 0 {
+		return fmt.Errorf("thinkingBudget must not be negative")
+	}
+	for col, values := range o.AllowedValues {
+		if !strings.Contains(col, ".") {
+			return fmt.Errorf("allowedValues key %q must be in table.column form", col)
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("allowedValues for %s must not be empty", col)
+		}
+	}
+	for table, n := range o.RowTargets {
+		if n < 0 {
+			return fmt.Errorf("row target for %s must not be negative", table)
+		}
+	}
+	if o.RowsPerTable < 0 || o.PriorRows < 0 {
+		return fmt.Errorf("row counts must not be negative")
+	}
+	if o.TimeRange != nil {
+		start, end, err := o.TimeRange.Bounds()
+		if err != nil {
+			return err
+		}
+		if start.After(end) {
+			return fmt.Errorf("timeRange start must not be after end")
+		}
+	}
+	for col, rng := range o.NumericRanges {
+		if !strings.Contains(col, ".") {
+			return fmt.Errorf("numericRanges key %q must be in table.column form", col)
+		}
+		if rng.Min > rng.Max {
+			return fmt.Errorf("numericRanges for %s: min must not be greater than max", col)
+		}
+	}
+	return nil
+}
+
+// Generation is the result of GenerateDataSQL.
+type Generation struct {
+	SQL string
+	// Warnings lists non-fatal problems, such as output cut off at the
+	// token limit.
+	Warnings []string
+}
+
+// MinRequestedStatements is the fewest INSERT statements the generation
+// prompt asks for.
+const MinRequestedStatements = 15
 
-	c.model.SystemInstruction = genai.NewUserContent(genai.Text("Eres un DBA que solo responde con código SQL INSERT. Estás prohibido de usar lenguaje natural. Genera exclusivamente sentencias SQL INSERT válidas para las tablas proporcionadas."))
+// ErrUnsupportedOption is returned by CheckOptions for generation options
+// that the Gemini SDK in use has no setting for.
+var ErrUnsupportedOption = errors.New("not supported by the gemini provider")
+
+// CheckOptions reports options that GenerateDataSQL can't honor: a thinking
+// budget, as the SDK's GenerationConfig has no thinking config.
+func (c *Client) CheckOptions(opts GenerateOptions) error {
+	if opts.ThinkingBudget != 0 {
+		return fmt.Errorf("thinkingBudget is %w", ErrUnsupportedOption)
+	}
+	return nil
+}
+
+// GenerateDataSQL asks Gemini to generate INSERT statements based on the schema
+func (c *Client) GenerateDataSQL(ctx context.Context, schema string, opts GenerateOptions) (*Generation, error) {
+	// A model handle of its own, so these settings neither leak into other
+	// calls nor race with concurrent requests
+	model := c.genaiClient.GenerativeModel(c.modelName)
+	model.SetTemperature(opts.Temperature)
+	model.SetMaxOutputTokens(int32(opts.MaxTokens))
+	if preset, err := LookupPreset(opts.Preset); err == nil {
+		model.SetTopP(preset.TopP)
+	}
+	model.StopSequences = opts.StopSequences
+	if opts.TopK > 0 {
+		model.SetTopK(int32(opts.TopK))
+	}
+	if opts.CandidateCount > 0 {
+		model.SetCandidateCount(int32(opts.CandidateCount))
+	}
 
-	prompt := fmt.Sprintf("Schema:\n%s\n\nTask: Generate 15-20 INSERT statements with UNIQUE and VARIED realistic dummy data. For unique fields like username/email, add random numbers or timestamps to ensure uniqueness (e.g., user123, john.doe.456@example.com). Use single quotes for strings and escape any quotes inside strings properly. Output only valid PostgreSQL INSERT statements, no markdown, no explanations.", schema)
+	// With a cached schema only the task is sent; the system instruction
+	// and schema come from the cache
+	system, prompt := c.GenerationPrompt(schema, opts)
+	reservation, err := c.reserve(ctx, system, prompt, int32(opts.MaxTokens))
+	if err != nil {
+		return nil, err
+	}
 
-	resp, err := c.model.GenerateContent(ctx, genai.Text(prompt))
+	var resp *genai.GenerateContentResponse
+	if cached := c.schemas.lookup(ctx, c.genaiClient, c.modelName, schema); cached != nil {
+		cachedModel := c.genaiClient.GenerativeModelFromCachedContent(cached)
+		cachedModel.GenerationConfig = model.GenerationConfig
+		resp, err = cachedModel.GenerateContent(ctx, genai.Text(generationTask(schema, opts)))
+	} else {
+		model.SystemInstruction = genai.NewUserContent(genai.Text(system))
+		resp, err = model.GenerateContent(ctx, genai.Text(prompt))
+	}
+	c.recordUsage(reservation, resp)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return getResponseText(resp), nil
+	gen := &Generation{SQL: getResponseText(resp)}
+	candidate := pickCandidate(resp.Candidates)
+	switch {
+	case candidate == nil:
+		gen.Warnings = append(gen.Warnings, "the model returned no content")
+	case candidate.FinishReason == genai.FinishReasonMaxTokens:
+		// The last statement is almost certainly cut off, so keep only
+		// the complete ones
+		if i := strings.LastIndex(gen.SQL, ";"); i >= 0 {
+			gen.SQL = gen.SQL[:i+1]
+		}
+		gen.Warnings = append(gen.Warnings, "output reached the maxTokens limit; the incomplete last statement was dropped")
+	case candidate.FinishReason != genai.FinishReasonStop:
+		gen.Warnings = append(gen.Warnings, fmt.Sprintf("output stopped early (finish reason: %s)", candidate.FinishReason))
+	}
+	return gen, nil
+}
+
+// GenerationPrompt returns the system instruction and the prompt that
+// GenerateDataSQL sends for schema and opts.
+func (c *Client) GenerationPrompt(schema string, opts GenerateOptions) (system, prompt string) {
+	return generationSystem, schemaContext(schema) + "\n\n" + generationTask(schema, opts)
+}
+
+const generationSystem = "Eres un DBA que solo responde con código SQL INSERT. Estás prohibido de usar lenguaje natural. Genera exclusivamente sentencias SQL INSERT válidas para las tablas proporcionadas."
+
+// schemaContext is the part of the generation prompt that only depends on
+// the schema, and so can be cached.
+func schemaContext(schema string) string {
+	return "Schema:\n" + schema
+}
+
+// generationTask is the per-request part of the generation prompt.
+func generationTask(schema string, opts GenerateOptions) string {
+	count := fmt.Sprintf("Generate %d-%d INSERT statements", MinRequestedStatements, MinRequestedStatements+5)
+	if opts.RowsPerTable > 0 {
+		count = fmt.Sprintf("Insert exactly %d rows into each table, using multi-row INSERT statements and numbering explicit integer keys from 1 unless told otherwise below,", opts.RowsPerTable)
+	}
+	prompt := fmt.Sprintf("Task: %s with UNIQUE and VARIED realistic dummy data. Tables are listed with referenced tables before the tables that reference them; insert in that order and only reference rows you have already inserted. A table's foreign keys are listed under it as FOREIGN KEY (column) REFERENCES parent(key); every such column must hold the key of an existing parent row, never an invented one. For unique fields like username/email, add random numbers or timestamps to ensure uniqueness (e.g., user123, john.doe.456@example.com). Use single quotes for strings and escape any quotes inside strings properly. Text values must never exceed the maximum length shown in parentheses after a column's type, e.g. varchar(50) allows at most 50 characters. Output only valid %s INSERT statements, no markdown, no explanations.", count, DialectName(opts.Dialect))
+	if strings.Contains(schema, "[]") {
+		prompt += " Array columns, whose type ends in [], take ARRAY constructors or array literals, e.g. ARRAY['red','blue'] or '{1,2,3}'."
+	}
+	prompt += GenerationHints(opts)
+	return prompt
 }
 
 // NaturalLanguageToSQL asks Gemini to convert a prompt to a SELECT query
 func (c *Client) NaturalLanguageToSQL(ctx context.Context, schema string, userPrompt string) (string, bool, error) {
+	return c.naturalLanguageToSQL(ctx, c.genaiClient.GenerativeModel(c.modelName), schema, userPrompt)
+}
+
+// NaturalLanguageToSQLWithModel is like NaturalLanguageToSQL but runs against
+// the named model instead of the client's default one. Each call gets its own
+// model handle, so it is safe to call concurrently.
+func (c *Client) NaturalLanguageToSQLWithModel(ctx context.Context, modelName, schema, userPrompt string) (string, bool, error) {
+	return c.naturalLanguageToSQL(ctx, c.genaiClient.GenerativeModel(modelName), schema, userPrompt)
+}
+
+func (c *Client) naturalLanguageToSQL(ctx context.Context, model *genai.GenerativeModel, schema string, userPrompt string) (string, bool, error) {
+	const maxOutput = 1024
+
 	// Reset to default config for analysis
-	c.model.SetTemperature(0.1) // Low temperature for deterministic SQL
-	c.model.SetMaxOutputTokens(1024)
+	model.SetTemperature(c.queryTemp)
+	model.SetMaxOutputTokens(maxOutput)
+
+	system, input := c.QueryPrompt(schema, userPrompt, time.Now())
+	reservation, err := c.reserve(ctx, system, input, maxOutput)
+	if err != nil {
+		return "", false, err
+	}
+	model.SystemInstruction = genai.NewUserContent(genai.Text(system))
+
+	resp, err := model.GenerateContent(ctx, genai.Text(input))
+	c.recordUsage(reservation, resp)
+	if err != nil {
+		return "", false, err
+	}
+
+	text := getResponseText(resp)
+
+	// Clean up markdown code blocks if present
+	text = strings.TrimPrefix(text, "```sql")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+	text = strings.TrimSpace(text)
+
+	_, _, isChart := ParseChartMarker(text)
+
+	return text, isChart, nil
+}
+
+// dialectPrompt is what the prompts say about a SQL dialect.
+type dialectPrompt struct {
+	name string
+	// relativeDates are example expressions for relative dates
+	relativeDates string
+	// month is an example expression for the month of created_at
+	month string
+}
+
+// dialectPrompts maps the dialects of DATABASE_DIALECT to their prompt
+// wording.
+var dialectPrompts = map[string]dialectPrompt{
+	"postgres": {"PostgreSQL", "CURRENT_DATE - INTERVAL '1 month' or date_trunc('month', CURRENT_DATE)", "date_trunc('month', created_at)"},
+	"mysql":    {"MySQL", "CURDATE() - INTERVAL 1 MONTH or DATE_FORMAT(CURDATE(), '%Y-%m-01')", "DATE_FORMAT(created_at, '%Y-%m-01')"},
+	"sqlite":   {"SQLite", "date('now', '-1 month') or date('now', 'start of month')", "date(created_at, 'start of month')"},
+}
+
+func promptFor(dialect string) dialectPrompt {
+	if p, ok := dialectPrompts[dialect]; ok {
+		return p
+	}
+	return dialectPrompts["postgres"]
+}
+
+// DialectName returns the name the prompts use for a dialect given as in
+// DATABASE_DIALECT, e.g. MySQL for mysql. Empty or unknown dialects are
+// PostgreSQL.
+func DialectName(dialect string) string {
+	return promptFor(dialect).name
+}
 
-	c.model.SystemInstruction = genai.NewUserContent(genai.Text(`You are a database analyst assistant. You ONLY generate SELECT queries.
+// QueryPrompt returns the system instruction and the prompt that
+// NaturalLanguageToSQL sends for schema and userPrompt, asked at now.
+func (c *Client) QueryPrompt(schema, userPrompt string, now time.Time) (system, input string) {
+	d := promptFor(c.dialect)
+	system = `You are a database analyst assistant. You ONLY generate SELECT queries.
 
 Rules:
 1. If user asks to modify data (DROP, DELETE, UPDATE, etc), respond with 'ERROR: Unauthorized'
-2. If user asks for a chart, graph, or visualization (keywords: chart, graph, plot, show, draw, visualize), you MUST:
+2. If user asks for a chart, graph, or visualization in any language, matching keywords without regard to case or accents (keywords: ` + strings.Join(ChartKeywords(c.chartKeywords), ", ") + `), you MUST:
    - Generate a valid SELECT query that aggregates data
    - Add a comment line at the END: -- CHART: [type]
-   - Chart types: bar, pie, line, doughnut
+   - Chart types: bar, pie, line, doughnut. Always write the type in English, whatever the language of the question
 3. Output ONLY the SQL query with no explanations
+4. Write ` + d.name + ` SQL. Resolve relative dates ("today", "last week", "last month") against the current date given with the question, using ` + d.name + ` date functions rather than literal dates, e.g. ` + d.relativeDates + `
 
 Examples:
 - "show a bar chart of restaurants by city" → SELECT city, COUNT(*) as count FROM restaurants GROUP BY city; -- CHART: bar
-- "draw a pie chart of users by country" → SELECT country, COUNT(*) as total FROM users GROUP BY country; -- CHART: pie`))
+- "draw a pie chart of users by country" → SELECT country, COUNT(*) as total FROM users GROUP BY country; -- CHART: pie
+- "muestra un gráfico de líneas de pedidos por mes" → SELECT ` + d.month + ` AS month, COUNT(*) AS orders FROM orders GROUP BY month ORDER BY month; -- CHART: line`
+
+	input = fmt.Sprintf("Schema:\n%s\n\nCurrent date: %s\n\nUser Question: %s\n\nGenerate the SQL query (remember to add -- CHART: comment if visualization is requested):", schema, now.Format("2006-01-02 (Monday)"), userPrompt)
+	return system, input
+}
+
+// GenerationHints renders the optional per-request instructions that are
+// appended to the data generation prompt. They don't depend on the model, so
+// every provider's prompt ends with them.
+func GenerationHints(opts GenerateOptions) string {
+	var sb strings.Builder
+
+	if preset, err := LookupPreset(opts.Preset); err == nil && preset.Instruction != "" {
+		sb.WriteString("\n\n" + preset.Instruction + "\n")
+	}
+
+	if len(opts.ReferentialDensity) > 0 {
+		sb.WriteString("\n\nReferential density (fraction of parent rows that must be referenced by at least one child row; the remaining parents get no children):\n")
+		for _, fk := range sortedKeys(opts.ReferentialDensity) {
+			sb.WriteString(fmt.Sprintf("- %s: %.0f%%\n", fk, opts.ReferentialDensity[fk]*100))
+		}
+	}
+
+	if len(opts.NumericRanges) > 0 {
+		sb.WriteString("\n\nKeep these columns within the given inclusive ranges, spreading values realistically across each range:\n")
+		for _, col := range sortedKeys(opts.NumericRanges) {
+			rng := opts.NumericRanges[col]
+			sb.WriteString(fmt.Sprintf("- %s: %g to %g\n", col, rng.Min, rng.Max))
+		}
+	}
 
-	input := fmt.Sprintf("Schema:\n%s\n\nUser Question: %s\n\nGenerate the SQL query (remember to add -- CHART: comment if visualization is requested):", schema, userPrompt)
+	if rng := opts.TimeRange; rng != nil {
+		sb.WriteString(fmt.Sprintf("\n\nEvery date and timestamp column must fall between %s and %s inclusive.", rng.Start, rng.End))
+		if rng.BusinessHours {
+			sb.WriteString(" Skew timestamps toward business patterns: about 85% on weekdays between 09:00 and 18:00, busiest mid-morning and mid-afternoon with a dip at lunch, the rest thinly spread over evenings and weekends. Weekday dates should likewise outnumber weekend dates.")
+		} else {
+			sb.WriteString(" Spread values realistically across the range.")
+		}
+		sb.WriteString("\n")
+	}
 
-	resp, err := c.model.GenerateContent(ctx, genai.Text(input))
+	if len(opts.NullRates) > 0 {
+		sb.WriteString("\n\nLeave these columns NULL in the given share of rows, choosing the rows at random; every other nullable column should rarely be NULL:\n")
+		for _, col := range sortedKeys(opts.NullRates) {
+			sb.WriteString(fmt.Sprintf("- %s: %.0f%% NULL\n", col, opts.NullRates[col]*100))
+		}
+	}
+
+	if len(opts.KeyStarts) > 0 {
+		sb.WriteString("\n\nThe table already holds rows, so number these key columns consecutively starting from the given value:\n")
+		for _, col := range sortedKeys(opts.KeyStarts) {
+			sb.WriteString(fmt.Sprintf("- %s: from %d\n", col, opts.KeyStarts[col]))
+		}
+	}
+
+	if len(opts.RowTargets) > 0 {
+		sb.WriteString("\n\nInstead of the statement count above, insert exactly these numbers of rows, spread over as many statements as needed, and no rows into other tables:\n")
+		for _, table := range sortedKeys(opts.RowTargets) {
+			sb.WriteString(fmt.Sprintf("- %s: %d rows\n", table, opts.RowTargets[table]))
+		}
+	}
+
+	if opts.PriorRows > 0 {
+		sb.WriteString(fmt.Sprintf("\n\nEarlier batches of this generation already inserted %d rows into each table. Number new integer keys from %d on, except in key columns given a start above, use values for unique columns that earlier batches are unlikely to have used, and let foreign keys reference rows from any batch.\n", opts.PriorRows, opts.PriorRows+1))
+	}
+
+	if len(opts.LocalColumns) > 0 {
+		sb.WriteString("\n\nValues for these columns are filled in afterwards. Leave them out of both the column list and the VALUES of every INSERT, and always write an explicit column list:\n")
+		for _, col := range opts.LocalColumns {
+			sb.WriteString("- " + col + "\n")
+		}
+	}
+
+	if len(opts.LocalTables) > 0 {
+		sb.WriteString("\n\nRows for these tables are filled in afterwards. Don't insert into them:\n")
+		for _, table := range opts.LocalTables {
+			sb.WriteString("- " + table + "\n")
+		}
+	}
+
+	if len(opts.AllowedValues) > 0 {
+		sb.WriteString("\n\nThese columns must only take values from the given lists; any other value violates a foreign key:\n")
+		for _, col := range sortedKeys(opts.AllowedValues) {
+			quoted := make([]string, len(opts.AllowedValues[col]))
+			for i, v := range opts.AllowedValues[col] {
+				quoted[i] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+			}
+			sb.WriteString(fmt.Sprintf("- %s: %s\n", col, strings.Join(quoted, ", ")))
+		}
+	}
+
+	if opts.SafeText {
+		sb.WriteString("\n\nText values must not contain commas, semicolons, single or double quotes, backticks, backslashes, newlines, tabs or any other control characters. Rephrase values instead, e.g. write O Brien rather than O'Brien.\n")
+	}
+
+	if opts.SafeContent {
+		sb.WriteString("\n\nThe data will be shown in corporate demos. Never use profanity, slurs, insults, sexual, violent or otherwise offensive content, in any language. Do not use real people's personal data: invent names, emails, phone numbers and addresses instead of using those of real or famous people, and use reserved domains such as example.com.\n")
+	}
+
+	return sb.String()
+}
+
+// GenerateJSONRecords asks Gemini for count JSON records matching a JSON
+// Schema document and returns them as a JSON array.
+func (c *Client) GenerateJSONRecords(ctx context.Context, jsonSchema string, count int, temperature float32) (json.RawMessage, error) {
+	// A separate handle, since JSON output mode must not leak into the SQL methods
+	model := c.genaiClient.GenerativeModel(c.modelName)
+	model.SetTemperature(temperature)
+	model.ResponseMIMEType = "application/json"
+
+	system := "You generate realistic dummy data as JSON. Respond only with a JSON array of objects that validate against the given JSON Schema."
+	prompt := fmt.Sprintf("JSON Schema:\n%s\n\nTask: Generate %d records with UNIQUE and VARIED realistic values. Respect every type, format, enum, required property and min/max constraint in the schema.", jsonSchema, count)
+	// No output limit is set, so the budget is checked against an estimate
+	reservation, err := c.reserve(ctx, system, prompt, 0)
 	if err != nil {
-		return "", false, err
+		return nil, err
+	}
+	model.SystemInstruction = genai.NewUserContent(genai.Text(system))
+
+	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	c.recordUsage(reservation, resp)
+	if err != nil {
+		return nil, err
 	}
 
 	text := getResponseText(resp)
+	text = strings.TrimPrefix(text, "json")
 
-	// Clean up markdown code blocks if present
+	var records []json.RawMessage
+	if err := json.Unmarshal([]byte(text), &records); err != nil {
+		return nil, fmt.Errorf("model did not return a JSON array: %v", err)
+	}
+	return json.RawMessage(text), nil
+}
+
+// ExplainSQL asks Gemini to describe a query in plain language. Explanations
+// are cached by the hash of the SQL so repeated queries don't cost a call.
+func (c *Client) ExplainSQL(ctx context.Context, sql string) (string, error) {
+	sum := sha256.Sum256([]byte(sql))
+	key := hex.EncodeToString(sum[:])
+
+	c.explainMu.Lock()
+	cached, ok := c.explanations[key]
+	c.explainMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	const maxOutput = 512
+
+	// A model handle of its own, so the settings don't race with other calls
+	model := c.genaiClient.GenerativeModel(c.modelName)
+	model.SetTemperature(0.2)
+	model.SetMaxOutputTokens(maxOutput)
+
+	system := "You explain SQL queries to non-technical users. Describe in two or three plain-language sentences what data the query returns. Do not include SQL, markdown, or column type details."
+	reservation, err := c.reserve(ctx, system, sql, maxOutput)
+	if err != nil {
+		return "", err
+	}
+	model.SystemInstruction = genai.NewUserContent(genai.Text(system))
+
+	resp, err := model.GenerateContent(ctx, genai.Text(sql))
+	c.recordUsage(reservation, resp)
+	if err != nil {
+		return "", err
+	}
+
+	explanation := getResponseText(resp)
+
+	c.explainMu.Lock()
+	if len(c.explanations) >= maxCachedExplanations {
+		c.explanations = make(map[string]string)
+	}
+	c.explanations[key] = explanation
+	c.explainMu.Unlock()
+
+	return explanation, nil
+}
+
+// DescribeTables asks Gemini what each table in schema is for, and returns
+// a one-sentence description per table name.
+func (c *Client) DescribeTables(ctx context.Context, schema string) (map[string]string, error) {
+	const maxOutput = 4096
+
+	// JSON output mode, on a handle of its own like GenerateJSONRecords
+	model := c.genaiClient.GenerativeModel(c.modelName)
+	model.SetTemperature(0.2)
+	model.SetMaxOutputTokens(maxOutput)
+	model.ResponseMIMEType = "application/json"
+
+	system, prompt := describeTablesPrompt(schema)
+	reservation, err := c.reserve(ctx, system, prompt, maxOutput)
+	if err != nil {
+		return nil, err
+	}
+	model.SystemInstruction = genai.NewUserContent(genai.Text(system))
+
+	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	c.recordUsage(reservation, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var descriptions map[string]string
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(getResponseText(resp), "json")), &descriptions); err != nil {
+		return nil, fmt.Errorf("model did not return a JSON object: %v", err)
+	}
+	return descriptions, nil
+}
+
+// describeTablesPrompt returns the system instruction and the prompt that
+// DescribeTables sends for schema.
+func describeTablesPrompt(schema string) (system, prompt string) {
+	system = "You document databases for analysts. Respond only with a JSON object that maps every table name in the schema to one plain-language sentence describing what the table stores, judging from its name, its columns and the tables it relates to."
+	return system, "Schema:\n" + schema
+}
+
+// FixSQL asks Gemini to correct a generated statement that failed with
+// errMsg, given the schema it should match. It returns the corrected
+// statement only.
+func (c *Client) FixSQL(ctx context.Context, schema, stmt, errMsg string) (string, error) {
+	const maxOutput = 8192
+
+	// A model handle of its own, so the generation settings on the shared
+	// one are left alone
+	model := c.genaiClient.GenerativeModel(c.modelName)
+	model.SetTemperature(0)
+	model.SetMaxOutputTokens(maxOutput)
+
+	system := "You fix " + DialectName(c.dialect) + " statements that failed to execute. Reply with the corrected statement only: no markdown, no explanations. Keep the rows and values the same and only change what the error requires, using only tables and columns from the schema."
+	prompt := fmt.Sprintf("Schema:\n%s\n\nStatement:\n%s\n\nError:\n%s", schema, stmt, errMsg)
+	reservation, err := c.reserve(ctx, system, prompt, maxOutput)
+	if err != nil {
+		return "", err
+	}
+	model.SystemInstruction = genai.NewUserContent(genai.Text(system))
+
+	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	c.recordUsage(reservation, resp)
+	if err != nil {
+		return "", err
+	}
+
+	text := getResponseText(resp)
 	text = strings.TrimPrefix(text, "```sql")
 	text = strings.TrimPrefix(text, "```")
 	text = strings.TrimSuffix(text, "```")
 	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("the model returned no corrected statement")
+	}
+	return text, nil
+}
 
-	isChart := strings.Contains(text, "-- CHART:")
+// Ping checks that the API accepts the client's key, with a token count.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.CountTokens(ctx, "ping")
+	return err
+}
 
-	return text, isChart, nil
+// CountTokens returns how many tokens text takes up for the client's model,
+// for measuring prompt sizes.
+func (c *Client) CountTokens(ctx context.Context, text string) (int32, error) {
+	resp, err := c.genaiClient.GenerativeModel(c.modelName).CountTokens(ctx, genai.Text(text))
+	if err != nil {
+		return 0, err
+	}
+	return resp.TotalTokens, nil
+}
+
+// sortedKeys returns the keys of m in sorted order, so prompts are stable.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 func getResponseText(resp *genai.GenerateContentResponse) string {
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
+	candidate := pickCandidate(resp.Candidates)
+	if candidate == nil {
 		return ""
 	}
 
 	var sb strings.Builder
-	for _, part := range resp.Candidates[0].Content.Parts {
+	for _, part := range candidate.Content.Parts {
 		if txt, ok := part.(genai.Text); ok {
 			sb.WriteString(string(txt))
 		}
@@ -115,3 +766,21 @@ func getResponseText(resp *genai.GenerateContentResponse) string {
 
 	return text
 }
+
+// pickCandidate returns the first candidate that finished normally, or else
+// the first one with any content.
+func pickCandidate(candidates []*genai.Candidate) *genai.Candidate {
+	var fallback *genai.Candidate
+	for _, c := range candidates {
+		if c == nil || c.Content == nil || len(c.Content.Parts) == 0 {
+			continue
+		}
+		if c.FinishReason == genai.FinishReasonStop {
+			return c
+		}
+		if fallback == nil {
+			fallback = c
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,32 @@
+package gemini
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGenerationPromptArrayColumns(t *testing.T) {
+	c := &Client{}
+	const hint = "ARRAY constructors"
+
+	if _, prompt := c.GenerationPrompt("posts(id integer, tags text[])\n", GenerateOptions{}); !strings.Contains(prompt, hint) {
+		t.Errorf("prompt for a tags text[] column doesn't explain array values:\n%s", prompt)
+	}
+	if _, prompt := c.GenerationPrompt("posts(id integer, title text)\n", GenerateOptions{}); strings.Contains(prompt, hint) {
+		t.Error("prompt without array columns explains array values")
+	}
+}
+
+func TestCheckOptionsThinkingBudget(t *testing.T) {
+	c := &Client{}
+	if err := c.CheckOptions(GenerateOptions{}); err != nil {
+		t.Errorf("CheckOptions without a thinking budget = %v, want nil", err)
+	}
+	if err := c.CheckOptions(GenerateOptions{ThinkingBudget: 1024}); !errors.Is(err, ErrUnsupportedOption) {
+		t.Errorf("CheckOptions with a thinking budget = %v, want ErrUnsupportedOption", err)
+	}
+	if err := (GenerateOptions{ThinkingBudget: -1}).Validate(); err == nil {
+		t.Error("a negative thinking budget passed Validate")
+	}
+}
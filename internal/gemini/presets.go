@@ -0,0 +1,46 @@
+package gemini
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Preset bundles the generation knobs for a point on the trade-off between
+// strictly valid, canonical data and varied, creative data.
+type Preset struct {
+	Temperature float32
+	TopP        float32
+	Instruction string // appended to the generation prompt, may be empty
+}
+
+// DefaultPreset is used when a request names no preset.
+const DefaultPreset = "balanced"
+
+var presets = map[string]Preset{
+	"strict": {
+		Temperature: 0.2,
+		TopP:        0.8,
+		Instruction: "Favor canonical, conservative values: respect every constraint, use well-known realistic names, round numbers and typical dates, and avoid edge cases.",
+	},
+	"balanced": {
+		Temperature: 0.7,
+		TopP:        0.95,
+	},
+	"creative": {
+		Temperature: 1.2,
+		TopP:        1,
+		Instruction: "Favor variety: use diverse names, locales, lengths and value distributions, and include plausible edge cases, while still respecting every constraint.",
+	},
+}
+
+// LookupPreset returns the named preset, or DefaultPreset for an empty name.
+func LookupPreset(name string) (Preset, error) {
+	if name == "" {
+		name = DefaultPreset
+	}
+	preset, ok := presets[name]
+	if !ok {
+		return Preset{}, fmt.Errorf("preset must be one of %s", strings.Join(sortedKeys(presets), ", "))
+	}
+	return preset, nil
+}
@@ -0,0 +1,137 @@
+package gemini
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// schemaCacheMargin is how long before Gemini expires a cached schema that
+// it stops being handed out, so a request never starts on a dying cache.
+const schemaCacheMargin = time.Minute
+
+// schemaCacheTimeout bounds creating a cached schema. The call runs detached
+// from the request that started it, since others may be waiting on it.
+const schemaCacheTimeout = 30 * time.Second
+
+// schemaCache tracks the generation schemas held in Gemini's context cache,
+// keyed by model and schema hash, so repeated generations against the same
+// schema send only the task. Schemas that can't be cached, typically because
+// they are below the API's minimum token count, are remembered for the same
+// TTL so the attempt isn't repeated on every request.
+type schemaCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]schemaCacheEntry
+	// pending holds a channel per key being created, closed when done, so
+	// concurrent requests for a new schema create it only once
+	pending map[string]chan struct{}
+}
+
+type schemaCacheEntry struct {
+	content *genai.CachedContent // nil when caching failed
+	expires time.Time
+}
+
+func newSchemaCache(ttl time.Duration) *schemaCache {
+	return &schemaCache{
+		ttl:     ttl,
+		entries: make(map[string]schemaCacheEntry),
+		pending: make(map[string]chan struct{}),
+	}
+}
+
+// lookup returns the cached content holding the generation system
+// instruction and schema, creating it on first use. It returns nil when
+// caching is disabled or unavailable, and the schema must be sent inline.
+func (sc *schemaCache) lookup(ctx context.Context, client *genai.Client, model, schema string) *genai.CachedContent {
+	if sc.ttl <= 0 {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(model + "\x00" + schema))
+	return sc.load(ctx, hex.EncodeToString(sum[:]), func(ctx context.Context) (*genai.CachedContent, error) {
+		return client.CreateCachedContent(ctx, &genai.CachedContent{
+			Model:             model,
+			SystemInstruction: genai.NewUserContent(genai.Text(generationSystem)),
+			Contents:          []*genai.Content{genai.NewUserContent(genai.Text(schemaContext(schema)))},
+			Expiration:        genai.ExpireTimeOrTTL{TTL: sc.ttl},
+		})
+	})
+}
+
+// load returns the entry for key, calling create when there is none and no
+// other request is already creating it. A caller whose ctx ends while it
+// waits gets nil. Failures are remembered unless create timed out or was
+// canceled, which says nothing about whether the schema can be cached.
+func (sc *schemaCache) load(ctx context.Context, key string, create func(context.Context) (*genai.CachedContent, error)) *genai.CachedContent {
+	for {
+		sc.mu.Lock()
+		now := time.Now()
+		if entry, ok := sc.entries[key]; ok && now.Before(entry.expires) {
+			sc.mu.Unlock()
+			return entry.content
+		}
+		done, waiting := sc.pending[key]
+		if !waiting {
+			sc.evictExpired(now)
+			done = make(chan struct{})
+			sc.pending[key] = done
+		}
+		sc.mu.Unlock()
+
+		if waiting {
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		createCtx, cancel := context.WithTimeout(context.Background(), schemaCacheTimeout)
+		content, err := create(createCtx)
+		cancel()
+
+		sc.mu.Lock()
+		delete(sc.pending, key)
+		close(done)
+		switch {
+		case err == nil:
+			sc.entries[key] = schemaCacheEntry{content: content, expires: now.Add(sc.ttl - min(schemaCacheMargin, sc.ttl/2))}
+		case !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled):
+			content = nil
+			sc.entries[key] = schemaCacheEntry{expires: now.Add(sc.ttl - min(schemaCacheMargin, sc.ttl/2))}
+		default:
+			content = nil
+		}
+		sc.mu.Unlock()
+		return content
+	}
+}
+
+func (sc *schemaCache) evictExpired(now time.Time) {
+	for key, entry := range sc.entries {
+		if !now.Before(entry.expires) {
+			delete(sc.entries, key)
+		}
+	}
+}
+
+// release deletes the schemas this client cached that are still live,
+// rather than leaving them to expire, when the client is closed.
+func (sc *schemaCache) release(client *genai.Client) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for key, entry := range sc.entries {
+		if entry.content != nil {
+			client.DeleteCachedContent(context.Background(), entry.content.Name)
+		}
+		delete(sc.entries, key)
+	}
+}
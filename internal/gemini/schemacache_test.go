@@ -0,0 +1,107 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestSchemaCacheCreatesOncePerKey(t *testing.T) {
+	sc := newSchemaCache(time.Hour)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	create := func(ctx context.Context) (*genai.CachedContent, error) {
+		calls.Add(1)
+		<-release
+		return &genai.CachedContent{Name: "cachedContents/1"}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]*genai.CachedContent, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = sc.load(context.Background(), "key", create)
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("create called %d times, want 1", n)
+	}
+	for i, content := range results {
+		if content == nil || content.Name != "cachedContents/1" {
+			t.Errorf("caller %d got %v", i, content)
+		}
+	}
+}
+
+func TestSchemaCacheWaiterStopsWithItsContext(t *testing.T) {
+	sc := newSchemaCache(time.Hour)
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	go sc.load(context.Background(), "key", func(ctx context.Context) (*genai.CachedContent, error) {
+		close(started)
+		<-release
+		return nil, errors.New("too small to cache")
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if content := sc.load(ctx, "key", nil); content != nil {
+		t.Errorf("waiter got %v, want nil", content)
+	}
+}
+
+func TestSchemaCacheRemembersOnlyRealFailures(t *testing.T) {
+	sc := newSchemaCache(time.Hour)
+	calls := 0
+	fail := func(err error) func(context.Context) (*genai.CachedContent, error) {
+		return func(ctx context.Context) (*genai.CachedContent, error) {
+			calls++
+			return nil, err
+		}
+	}
+
+	sc.load(context.Background(), "slow", fail(context.DeadlineExceeded))
+	sc.load(context.Background(), "slow", fail(context.DeadlineExceeded))
+	if calls != 2 {
+		t.Errorf("a timed out create was remembered: %d calls, want 2", calls)
+	}
+
+	calls = 0
+	sc.load(context.Background(), "small", fail(errors.New("too small to cache")))
+	sc.load(context.Background(), "small", fail(errors.New("too small to cache")))
+	if calls != 1 {
+		t.Errorf("a failed create was retried: %d calls, want 1", calls)
+	}
+}
+
+func TestSchemaCacheCreateIgnoresRequestCancellation(t *testing.T) {
+	sc := newSchemaCache(time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	content := sc.load(ctx, "key", func(ctx context.Context) (*genai.CachedContent, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("create ran without a deadline")
+		}
+		return &genai.CachedContent{Name: "cachedContents/1"}, nil
+	})
+	if content == nil {
+		t.Error("create was canceled with the request")
+	}
+}
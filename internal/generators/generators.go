@@ -0,0 +1,123 @@
+package generators
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"genai/internal/database"
+)
+
+// Generator produces one value for a column. The value is inserted as a SQL
+// string literal, which Postgres casts to the column type.
+type Generator func() string
+
+type entry struct {
+	pattern *regexp.Regexp
+	gen     Generator
+}
+
+// Registry maps column-name patterns to generators whose values replace the
+// ones produced by the model. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	entries []entry
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a generator for columns whose name matches pattern. The first
+// registered pattern that matches a column wins.
+func (r *Registry) Register(pattern string, gen Generator) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid generator pattern %q: %v", pattern, err)
+	}
+	r.mu.Lock()
+	r.entries = append(r.entries, entry{pattern: re, gen: gen})
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Registry) lookup(column string) Generator {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, e := range r.entries {
+		if e.pattern.MatchString(column) {
+			return e.gen
+		}
+	}
+	return nil
+}
+
+// Apply overrides the values of matching columns in an INSERT statement.
+// Statements that can't be parsed, or that have no column list, are returned
+// unchanged.
+func (r *Registry) Apply(stmt string) string {
+	r.mu.RLock()
+	empty := len(r.entries) == 0
+	r.mu.RUnlock()
+	if empty {
+		return stmt
+	}
+
+	ins, err := database.ParseInsert(stmt)
+	if err != nil || len(ins.Columns) == 0 {
+		return stmt
+	}
+
+	changed := false
+	for i, col := range ins.Columns {
+		gen := r.lookup(database.UnquoteIdentifier(col))
+		if gen == nil {
+			continue
+		}
+		for _, row := range ins.Rows {
+			if i < len(row) {
+				row[i] = database.QuoteLiteral(gen())
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return stmt
+	}
+	return ins.String()
+}
+
+// Luhn returns a generator of random numeric strings of the given length
+// whose last digit is a Luhn check digit, a common format for account and
+// card-like numbers.
+func Luhn(length int) Generator {
+	return func() string {
+		digits := make([]int, length)
+		for i := 0; i < length-1; i++ {
+			digits[i] = rand.Intn(10)
+		}
+
+		sum := 0
+		for i := length - 2; i >= 0; i-- {
+			d := digits[i]
+			if (length-2-i)%2 == 0 {
+				d *= 2
+				if d > 9 {
+					d -= 9
+				}
+			}
+			sum += d
+		}
+		digits[length-1] = (10 - sum%10) % 10
+
+		out := make([]byte, 0, length)
+		for _, d := range digits {
+			out = strconv.AppendInt(out, int64(d), 10)
+		}
+		return string(out)
+	}
+}
@@ -0,0 +1,132 @@
+package generators
+
+import (
+	"crypto/rand"
+	"fmt"
+	mathrand "math/rand"
+	"slices"
+	"strconv"
+	"time"
+
+	"genai/internal/database"
+)
+
+// UUID returns a generator of random version 4 UUIDs.
+func UUID() Generator {
+	return func() string {
+		var b [16]byte
+		rand.Read(b[:])
+		b[6] = b[6]&0x0f | 0x40 // version 4
+		b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	}
+}
+
+// Bool returns a generator of random booleans.
+func Bool() Generator {
+	return func() string {
+		return strconv.FormatBool(mathrand.Intn(2) == 0)
+	}
+}
+
+// Time returns a generator of random times between start and end, formatted
+// with layout.
+func Time(start, end time.Time, layout string) Generator {
+	span := end.Sub(start)
+	return func() string {
+		if span <= 0 {
+			return start.Format(layout)
+		}
+		return start.Add(time.Duration(mathrand.Int63n(int64(span)))).Format(layout)
+	}
+}
+
+// Sequence returns a generator of consecutive integers from start. Unlike
+// the other generators it has state, so each use needs its own.
+func Sequence(start int64) Generator {
+	next := start
+	return func() string {
+		n := next
+		next++
+		return strconv.FormatInt(n, 10)
+	}
+}
+
+// ForType returns a generator for columns of an information_schema data
+// type whose values need no understanding of what the column means: UUIDs,
+// booleans, dates and timestamps, the latter between start and end. It
+// returns nil for any other type.
+func ForType(dataType string, start, end time.Time) Generator {
+	switch dataType {
+	case "uuid":
+		return UUID()
+	case "boolean":
+		return Bool()
+	case "date":
+		return Time(start, end, "2006-01-02")
+	case "timestamp without time zone":
+		return Time(start, end, "2006-01-02 15:04:05")
+	case "timestamp with time zone":
+		return Time(start, end, time.RFC3339)
+	}
+	return nil
+}
+
+// LocalColumn is a column whose values are generated locally rather than by
+// the model.
+type LocalColumn struct {
+	Name string
+	Gen  Generator
+}
+
+// Plan maps a table to its locally generated columns, for hybrid
+// generation: the model is told to leave those columns out, and Fill adds
+// them to its INSERTs.
+type Plan map[string][]LocalColumn
+
+// Columns lists the planned columns as table.column, sorted.
+func (p Plan) Columns() []string {
+	var cols []string
+	for table, local := range p {
+		for _, col := range local {
+			cols = append(cols, table+"."+col.Name)
+		}
+	}
+	slices.Sort(cols)
+	return cols
+}
+
+// Fill adds the planned columns a statement leaves out, with a generated
+// value for every row. Statements that can't be parsed, or that have no
+// column list, are returned unchanged.
+func (p Plan) Fill(stmt string) string {
+	ins, err := database.ParseInsert(stmt)
+	if err != nil || len(ins.Columns) == 0 {
+		return stmt
+	}
+	local := p[ins.TableName()]
+	if len(local) == 0 {
+		return stmt
+	}
+
+	present := make(map[string]bool, len(ins.Columns))
+	for _, col := range ins.Columns {
+		present[database.UnquoteIdentifier(col)] = true
+	}
+	changed := false
+	for _, col := range local {
+		if present[col.Name] {
+			continue
+		}
+		ins.Columns = append(ins.Columns, database.QuoteIdentifier(col.Name))
+		for i := range ins.Rows {
+			ins.Rows[i] = append(ins.Rows[i], database.QuoteLiteral(col.Gen()))
+		}
+		changed = true
+	}
+
+	if !changed {
+		return stmt
+	}
+	return ins.String()
+}
@@ -0,0 +1,307 @@
+// Package openai talks to OpenAI-compatible chat completion APIs, as an
+// alternative to Gemini. Its prompts, in prompts.go, are written for chat
+// models; the options and result types are shared with the gemini package.
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"genai/internal/config"
+	"genai/internal/gemini"
+)
+
+// maxOutputEstimate stands in for the output of calls that don't set a
+// maximum, when estimating their cost against the token budget.
+const maxOutputEstimate = 8192
+
+// ErrUnsupportedOption is returned by CheckOptions for generation options
+// that the chat completions API has no equivalent of.
+var ErrUnsupportedOption = errors.New("not supported by the openai provider")
+
+// ErrTokenCountUnsupported is returned by CountTokens, as the chat
+// completions API has no token counting endpoint.
+var ErrTokenCountUnsupported = errors.New("token counting is not supported by the openai provider")
+
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	model      string
+
+	budget        *gemini.TokenBudget
+	chartKeywords []string
+	dialect       string
+	queryTemp     float32
+}
+
+// NewClient creates a client for cfg's OpenAI key, base URL and model whose
+// calls are charged to budget, which may be nil for no limits.
+func NewClient(cfg *config.Config, budget *gemini.TokenBudget) *Client {
+	return &Client{
+		httpClient:    &http.Client{Timeout: 5 * time.Minute},
+		baseURL:       strings.TrimSuffix(cfg.OpenAIBaseURL, "/"),
+		apiKey:        cfg.OpenAIKey,
+		model:         cfg.OpenAIModel,
+		budget:        budget,
+		chartKeywords: cfg.ChartKeywords,
+		dialect:       cfg.DatabaseDialect,
+		queryTemp:     cfg.QueryTemperature,
+	}
+}
+
+// Close is a no-op; the client holds no connections beyond the shared pool.
+func (c *Client) Close() {}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float32       `json:"temperature"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	TopP        float32       `json:"top_p,omitempty"`
+	Stop        []string      `json:"stop,omitempty"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message      chatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		TotalTokens int64 `json:"total_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// complete sends a system instruction and a prompt and returns the first
+// choice's text and finish reason.
+func (c *Client) complete(ctx context.Context, req chatRequest, system, prompt string) (string, string, error) {
+	// Without a tokenizer, estimate four characters per token
+	var reservation gemini.Reservation
+	if c.budget.Limited() {
+		output := int64(req.MaxTokens)
+		if output <= 0 {
+			output = maxOutputEstimate
+		}
+		var err error
+		if reservation, err = c.budget.Check(int64(len(system)+len(prompt))/4 + output); err != nil {
+			return "", "", err
+		}
+	}
+	// The reservation is replaced by the reported usage, or released if the
+	// call fails
+	var used int64
+	defer func() { c.budget.Record(reservation, used) }()
+
+	if req.Model == "" {
+		req.Model = c.model
+	}
+	req.Messages = []chatMessage{{Role: "system", Content: system}, {Role: "user", Content: prompt}}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", "", err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return "", "", err
+	}
+	defer httpResp.Body.Close()
+
+	var resp chatResponse
+	if err := json.NewDecoder(io.LimitReader(httpResp.Body, 16<<20)).Decode(&resp); err != nil {
+		return "", "", fmt.Errorf("reading response (status %d): %v", httpResp.StatusCode, err)
+	}
+	if resp.Error != nil {
+		return "", "", fmt.Errorf("%s (status %d)", resp.Error.Message, httpResp.StatusCode)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("unexpected status %d", httpResp.StatusCode)
+	}
+	used = resp.Usage.TotalTokens
+
+	if len(resp.Choices) == 0 {
+		return "", "", nil
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), resp.Choices[0].FinishReason, nil
+}
+
+// CheckOptions reports options that GenerateDataSQL can't honor: topK, more
+// than one candidate and a thinking budget.
+func (c *Client) CheckOptions(opts gemini.GenerateOptions) error {
+	if opts.ThinkingBudget != 0 {
+		return fmt.Errorf("thinkingBudget is %w", ErrUnsupportedOption)
+	}
+	if opts.TopK > 0 {
+		return fmt.Errorf("topK is %w", ErrUnsupportedOption)
+	}
+	if opts.CandidateCount > 1 {
+		return fmt.Errorf("candidateCount above 1 is %w", ErrUnsupportedOption)
+	}
+	return nil
+}
+
+// GenerateDataSQL asks the model to generate INSERT statements based on the
+// schema.
+func (c *Client) GenerateDataSQL(ctx context.Context, schema string, opts gemini.GenerateOptions) (*gemini.Generation, error) {
+	req := chatRequest{Temperature: opts.Temperature, MaxTokens: opts.MaxTokens, Stop: opts.StopSequences}
+	if preset, err := gemini.LookupPreset(opts.Preset); err == nil {
+		req.TopP = preset.TopP
+	}
+
+	if err := c.CheckOptions(opts); err != nil {
+		return nil, err
+	}
+	system, prompt := c.GenerationPrompt(schema, opts)
+	text, finishReason, err := c.complete(ctx, req, system, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	gen := &gemini.Generation{SQL: stripCodeFence(text)}
+	switch {
+	case gen.SQL == "":
+		gen.Warnings = append(gen.Warnings, "the model returned no content")
+	case finishReason == "length":
+		// The last statement is almost certainly cut off, so keep only
+		// the complete ones
+		if i := strings.LastIndex(gen.SQL, ";"); i >= 0 {
+			gen.SQL = gen.SQL[:i+1]
+		}
+		gen.Warnings = append(gen.Warnings, "output reached the maxTokens limit; the incomplete last statement was dropped")
+	case finishReason != "stop":
+		gen.Warnings = append(gen.Warnings, fmt.Sprintf("output stopped early (finish reason: %s)", finishReason))
+	}
+	return gen, nil
+}
+
+// NaturalLanguageToSQL asks the model to convert a prompt to a SELECT query.
+func (c *Client) NaturalLanguageToSQL(ctx context.Context, schema string, userPrompt string) (string, bool, error) {
+	return c.NaturalLanguageToSQLWithModel(ctx, c.model, schema, userPrompt)
+}
+
+// NaturalLanguageToSQLWithModel is like NaturalLanguageToSQL but runs against
+// the named model instead of the client's default one.
+func (c *Client) NaturalLanguageToSQLWithModel(ctx context.Context, modelName, schema, userPrompt string) (string, bool, error) {
+	system, input := c.QueryPrompt(schema, userPrompt, time.Now())
+	text, _, err := c.complete(ctx, chatRequest{Model: modelName, Temperature: c.queryTemp, MaxTokens: 1024}, system, input)
+	if err != nil {
+		return "", false, err
+	}
+	text = stripCodeFence(text)
+	_, _, isChart := gemini.ParseChartMarker(text)
+	return text, isChart, nil
+}
+
+// GenerateJSONRecords asks the model for count JSON records matching a JSON
+// Schema document and returns them as a JSON array.
+func (c *Client) GenerateJSONRecords(ctx context.Context, jsonSchema string, count int, temperature float32) (json.RawMessage, error) {
+	system := "You generate realistic dummy data as JSON. Respond only with a JSON array of objects that validate against the given JSON Schema, without markdown."
+	prompt := fmt.Sprintf("JSON Schema:\n%s\n\nTask: Generate %d records with UNIQUE and VARIED realistic values. Respect every type, format, enum, required property and min/max constraint in the schema.", jsonSchema, count)
+	text, _, err := c.complete(ctx, chatRequest{Temperature: temperature}, system, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	text = stripCodeFence(text)
+	var records []json.RawMessage
+	if err := json.Unmarshal([]byte(text), &records); err != nil {
+		return nil, fmt.Errorf("model did not return a JSON array: %v", err)
+	}
+	return json.RawMessage(text), nil
+}
+
+// ExplainSQL asks the model to describe a query in plain language.
+func (c *Client) ExplainSQL(ctx context.Context, sql string) (string, error) {
+	system := "You explain SQL queries to non-technical users. Describe in two or three plain-language sentences what data the query returns. Do not include SQL, markdown, or column type details."
+	text, _, err := c.complete(ctx, chatRequest{Temperature: 0.2, MaxTokens: 512}, system, sql)
+	return text, err
+}
+
+// DescribeTables asks the model what each table in schema is for, and
+// returns a one-sentence description per table name.
+func (c *Client) DescribeTables(ctx context.Context, schema string) (map[string]string, error) {
+	system, prompt := describeTablesPrompt(schema)
+	text, _, err := c.complete(ctx, chatRequest{Temperature: 0.2, MaxTokens: 4096}, system, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	var descriptions map[string]string
+	if err := json.Unmarshal([]byte(stripCodeFence(text)), &descriptions); err != nil {
+		return nil, fmt.Errorf("model did not return a JSON object: %v", err)
+	}
+	return descriptions, nil
+}
+
+// FixSQL asks the model to correct a generated statement that failed with
+// errMsg, given the schema it should match.
+func (c *Client) FixSQL(ctx context.Context, schema, stmt, errMsg string) (string, error) {
+	system := fixSQLSystem(c.dialect)
+	prompt := fmt.Sprintf("Schema:\n%s\n\nStatement:\n%s\n\nError:\n%s", schema, stmt, errMsg)
+	text, _, err := c.complete(ctx, chatRequest{Temperature: 0}, system, prompt)
+	if err != nil {
+		return "", err
+	}
+	if text = stripCodeFence(text); text == "" {
+		return "", fmt.Errorf("the model returned no corrected statement")
+	}
+	return text, nil
+}
+
+// CountTokens always fails with ErrTokenCountUnsupported.
+func (c *Client) CountTokens(ctx context.Context, text string) (int32, error) {
+	return 0, ErrTokenCountUnsupported
+}
+
+// Ping checks that the API accepts the client's key by listing models.
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// stripCodeFence removes a markdown code block around text, if present.
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	text = strings.TrimPrefix(text, "```")
+	if i := strings.IndexByte(text, '\n'); i >= 0 && !strings.ContainsAny(text[:i], " ;(") {
+		text = text[i+1:] // language tag, e.g. sql or json
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
@@ -0,0 +1,32 @@
+package openai
+
+import (
+	"errors"
+	"testing"
+
+	"genai/internal/gemini"
+)
+
+func TestCheckOptions(t *testing.T) {
+	c := &Client{}
+	tests := []struct {
+		name string
+		opts gemini.GenerateOptions
+		ok   bool
+	}{
+		{"defaults", gemini.GenerateOptions{}, true},
+		{"one candidate", gemini.GenerateOptions{CandidateCount: 1}, true},
+		{"topK", gemini.GenerateOptions{TopK: 40}, false},
+		{"several candidates", gemini.GenerateOptions{CandidateCount: 2}, false},
+		{"thinking budget", gemini.GenerateOptions{ThinkingBudget: 1024}, false},
+	}
+	for _, tt := range tests {
+		err := c.CheckOptions(tt.opts)
+		if tt.ok && err != nil {
+			t.Errorf("%s: CheckOptions = %v, want nil", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrUnsupportedOption) {
+			t.Errorf("%s: CheckOptions = %v, want ErrUnsupportedOption", tt.name, err)
+		}
+	}
+}
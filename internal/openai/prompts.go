@@ -0,0 +1,60 @@
+package openai
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"genai/internal/gemini"
+)
+
+// GenerationPrompt returns the system and user messages that GenerateDataSQL
+// sends for schema and opts.
+func (c *Client) GenerationPrompt(schema string, opts gemini.GenerateOptions) (system, prompt string) {
+	dialect := gemini.DialectName(opts.Dialect)
+	system = "You are a database administrator who writes test data. Reply only with valid " + dialect + " INSERT statements separated by semicolons: no markdown, no comments, no explanations."
+
+	count := fmt.Sprintf("Write %d to %d INSERT statements", gemini.MinRequestedStatements, gemini.MinRequestedStatements+5)
+	if opts.RowsPerTable > 0 {
+		count = fmt.Sprintf("Insert exactly %d rows into each table with multi-row INSERT statements, numbering explicit integer keys from 1 unless told otherwise below", opts.RowsPerTable)
+	}
+	var sb strings.Builder
+	sb.WriteString("Schema:\n" + schema + "\n\n")
+	sb.WriteString(count + " of unique, varied and realistic data for these tables.\n")
+	sb.WriteString("- Tables are listed with referenced tables first. Insert in that order.\n")
+	sb.WriteString("- Each FOREIGN KEY (column) REFERENCES parent(key) column must hold the key of a row already inserted into the parent.\n")
+	sb.WriteString("- Make unique values such as usernames and emails distinct, e.g. by adding numbers.\n")
+	sb.WriteString("- Quote strings with single quotes and escape quotes inside them.\n")
+	sb.WriteString("- Keep text within the length in parentheses after its type, e.g. varchar(50).\n")
+	if strings.Contains(schema, "[]") {
+		sb.WriteString("- Fill array columns, whose type ends in [], with ARRAY['red','blue'] or '{1,2,3}' literals.\n")
+	}
+	sb.WriteString(gemini.GenerationHints(opts))
+	return system, sb.String()
+}
+
+// QueryPrompt returns the system and user messages that NaturalLanguageToSQL
+// sends for schema and userPrompt, asked at now.
+func (c *Client) QueryPrompt(schema, userPrompt string, now time.Time) (system, input string) {
+	dialect := gemini.DialectName(c.dialect)
+	system = `You translate questions about a database into a single read-only ` + dialect + ` SELECT query. Reply with the query only, without markdown or explanations.
+
+- If the question asks to change data (INSERT, UPDATE, DELETE, DROP and the like), reply exactly: ERROR: Unauthorized
+- If the question asks for a chart, graph or visualization in any language (keywords, ignoring case and accents: ` + strings.Join(gemini.ChartKeywords(c.chartKeywords), ", ") + `), aggregate the data and end the query with a comment naming the chart type in English: -- CHART: bar, pie, line or doughnut
+- Resolve relative dates such as "last month" with ` + dialect + ` date functions against the current date, never with literal dates.`
+
+	input = fmt.Sprintf("Schema:\n%s\n\nCurrent date: %s\n\nQuestion: %s", schema, now.Format("2006-01-02 (Monday)"), userPrompt)
+	return system, input
+}
+
+// describeTablesPrompt returns the system and user messages that
+// DescribeTables sends for schema.
+func describeTablesPrompt(schema string) (system, prompt string) {
+	system = "You document databases for analysts. Reply only with a JSON object, without markdown, that maps every table name in the schema to one plain-language sentence saying what the table stores."
+	return system, "Schema:\n" + schema
+}
+
+// fixSQLSystem is the system message of FixSQL.
+func fixSQLSystem(dialect string) string {
+	return "You fix " + gemini.DialectName(dialect) + " statements that failed to execute. Reply with the corrected statement only, without markdown or explanations. Keep the same rows and values, change only what the error requires, and use only tables and columns from the schema."
+}
@@ -0,0 +1,92 @@
+package parquet
+
+import "encoding/binary"
+
+// Thrift compact protocol type codes.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thrift is a minimal Thrift compact protocol encoder, enough for the
+// Parquet page headers and file metadata.
+type thrift struct {
+	buf  []byte
+	last []int16 // last field id per open struct
+}
+
+func newThrift() *thrift {
+	return &thrift{last: []int16{0}}
+}
+
+func (t *thrift) bytes() []byte {
+	return t.buf
+}
+
+func (t *thrift) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.buf = binary.AppendVarint(t.buf, int64(id))
+	}
+	*last = id
+}
+
+func (t *thrift) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.buf = binary.AppendVarint(t.buf, int64(v))
+}
+
+func (t *thrift) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.buf = binary.AppendVarint(t.buf, v)
+}
+
+func (t *thrift) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.listBinary(s)
+}
+
+func (t *thrift) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.last = append(t.last, 0)
+}
+
+// beginElem starts a struct that is an element of a list.
+func (t *thrift) beginElem() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thrift) endStruct() {
+	t.buf = append(t.buf, 0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thrift) beginList(id int16, elemType byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf = append(t.buf, byte(size)<<4|elemType)
+	} else {
+		t.buf = append(t.buf, 0xF0|elemType)
+		t.buf = binary.AppendUvarint(t.buf, uint64(size))
+	}
+}
+
+func (t *thrift) listI32(v int32) {
+	t.buf = binary.AppendVarint(t.buf, int64(v))
+}
+
+func (t *thrift) listBinary(s string) {
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+// stop ends the top-level struct.
+func (t *thrift) stop() {
+	t.buf = append(t.buf, 0)
+}
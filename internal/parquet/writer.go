@@ -0,0 +1,366 @@
+// Package parquet writes uncompressed Parquet files with flat, nullable
+// columns. It supports just what the exporters need: PLAIN encoded values,
+// one data page per column chunk and row groups flushed as they fill up, so a
+// large table can be streamed without holding it in memory.
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// ColumnType is the logical type of a column.
+type ColumnType int
+
+const (
+	String ColumnType = iota
+	Boolean
+	Int32
+	Int64
+	Double
+	Timestamp // stored as INT64 microseconds since the Unix epoch, UTC
+	Date      // stored as INT32 days since the Unix epoch
+)
+
+// Column describes one column of the file.
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// RowGroupSize is the number of rows buffered before a row group is written.
+const RowGroupSize = 10000
+
+// Parquet physical types, repetition types, encodings and converted types
+// from the format's Thrift definitions.
+const (
+	physBoolean   = 0
+	physInt32     = 1
+	physInt64     = 2
+	physDouble    = 5
+	physByteArray = 6
+
+	repOptional = 1
+
+	encPlain = 0
+	encRLE   = 3
+
+	convUTF8            = 0
+	convDate            = 6
+	convTimestampMicros = 10
+
+	pageData = 0
+)
+
+type columnChunk struct {
+	offset     int64
+	size       int64
+	numValues  int64
+	columnType ColumnType
+}
+
+type rowGroup struct {
+	numRows int64
+	size    int64
+	columns []columnChunk
+}
+
+// Writer writes rows to a Parquet file. Call Close to write the footer.
+type Writer struct {
+	w       io.Writer
+	offset  int64
+	columns []Column
+	rows    [][]interface{}
+	groups  []rowGroup
+	total   int64
+	err     error
+}
+
+// NewWriter starts a Parquet file on w.
+func NewWriter(w io.Writer, columns []Column) *Writer {
+	pw := &Writer{w: w, columns: columns}
+	pw.write([]byte("PAR1"))
+	return pw
+}
+
+func (pw *Writer) write(b []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	pw.err = err
+}
+
+// WriteRow buffers a row. Values must match the column types (any Go integer
+// for Int32/Int64, float32/float64 for Double, time.Time for Timestamp and
+// Date, string or []byte for String) or be nil.
+func (pw *Writer) WriteRow(values []interface{}) error {
+	if pw.err != nil {
+		return pw.err
+	}
+	if len(values) != len(pw.columns) {
+		return fmt.Errorf("parquet: row has %d values, want %d", len(values), len(pw.columns))
+	}
+	pw.rows = append(pw.rows, values)
+	if len(pw.rows) >= RowGroupSize {
+		return pw.flush()
+	}
+	return nil
+}
+
+// flush writes the buffered rows as a row group.
+func (pw *Writer) flush() error {
+	if len(pw.rows) == 0 {
+		return pw.err
+	}
+
+	group := rowGroup{numRows: int64(len(pw.rows))}
+	for i, col := range pw.columns {
+		page, err := encodeColumn(col, pw.rows, i)
+		if err != nil {
+			pw.err = err
+			return err
+		}
+
+		header := newThrift()
+		header.i32(1, pageData)
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.beginStruct(5) // DataPageHeader
+		header.i32(1, int32(len(pw.rows)))
+		header.i32(2, encPlain)
+		header.i32(3, encRLE)
+		header.i32(4, encRLE)
+		header.endStruct()
+		header.stop()
+
+		chunk := columnChunk{offset: pw.offset, numValues: int64(len(pw.rows)), columnType: col.Type}
+		pw.write(header.bytes())
+		pw.write(page)
+		chunk.size = pw.offset - chunk.offset
+		group.size += chunk.size
+		group.columns = append(group.columns, chunk)
+	}
+
+	pw.groups = append(pw.groups, group)
+	pw.total += group.numRows
+	pw.rows = pw.rows[:0]
+	return pw.err
+}
+
+// Close flushes any buffered rows and writes the file footer. It does not
+// close the underlying writer.
+func (pw *Writer) Close() error {
+	if err := pw.flush(); err != nil {
+		return err
+	}
+
+	meta := newThrift()
+	meta.i32(1, 1) // version
+
+	meta.beginList(2, thriftStruct, len(pw.columns)+1)
+	meta.beginElem()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(pw.columns)))
+	meta.endStruct()
+	for _, col := range pw.columns {
+		physical, converted := physicalType(col.Type)
+		meta.beginElem()
+		meta.i32(1, physical)
+		meta.i32(3, repOptional)
+		meta.binary(4, col.Name)
+		if converted >= 0 {
+			meta.i32(6, converted)
+		}
+		meta.endStruct()
+	}
+
+	meta.i64(3, pw.total)
+
+	meta.beginList(4, thriftStruct, len(pw.groups))
+	for _, group := range pw.groups {
+		meta.beginElem()
+		meta.beginList(1, thriftStruct, len(group.columns))
+		for i, chunk := range group.columns {
+			physical, _ := physicalType(chunk.columnType)
+			meta.beginElem()
+			meta.i64(2, chunk.offset)
+			meta.beginStruct(3) // ColumnMetaData
+			meta.i32(1, physical)
+			meta.beginList(2, thriftI32, 2)
+			meta.listI32(encPlain)
+			meta.listI32(encRLE)
+			meta.beginList(3, thriftBinary, 1)
+			meta.listBinary(pw.columns[i].Name)
+			meta.i32(4, 0) // UNCOMPRESSED
+			meta.i64(5, chunk.numValues)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.endStruct()
+			meta.endStruct()
+		}
+		meta.i64(2, group.size)
+		meta.i64(3, group.numRows)
+		meta.endStruct()
+	}
+	meta.binary(6, "genai data assistant")
+	meta.stop()
+
+	footer := meta.bytes()
+	pw.write(footer)
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	pw.write(length[:])
+	pw.write([]byte("PAR1"))
+	return pw.err
+}
+
+func physicalType(t ColumnType) (physical int32, converted int32) {
+	switch t {
+	case Boolean:
+		return physBoolean, -1
+	case Int32:
+		return physInt32, -1
+	case Int64:
+		return physInt64, -1
+	case Double:
+		return physDouble, -1
+	case Timestamp:
+		return physInt64, convTimestampMicros
+	case Date:
+		return physInt32, convDate
+	default:
+		return physByteArray, convUTF8
+	}
+}
+
+// encodeColumn builds a v1 data page body: RLE encoded definition levels
+// followed by the PLAIN encoded non-null values.
+func encodeColumn(col Column, rows [][]interface{}, idx int) ([]byte, error) {
+	levels := make([]byte, len(rows))
+	var values []byte
+	var bits []bool
+
+	for r, row := range rows {
+		v := row[idx]
+		if v == nil {
+			continue
+		}
+		levels[r] = 1
+
+		switch col.Type {
+		case Boolean:
+			b, ok := v.(bool)
+			if !ok {
+				return nil, typeError(col, v)
+			}
+			bits = append(bits, b)
+		case Int32, Int64:
+			n, ok := toInt64(v)
+			if !ok {
+				return nil, typeError(col, v)
+			}
+			if col.Type == Int32 {
+				values = binary.LittleEndian.AppendUint32(values, uint32(int32(n)))
+			} else {
+				values = binary.LittleEndian.AppendUint64(values, uint64(n))
+			}
+		case Double:
+			var f float64
+			switch x := v.(type) {
+			case float64:
+				f = x
+			case float32:
+				f = float64(x)
+			default:
+				n, ok := toInt64(v)
+				if !ok {
+					return nil, typeError(col, v)
+				}
+				f = float64(n)
+			}
+			values = binary.LittleEndian.AppendUint64(values, math.Float64bits(f))
+		case Timestamp:
+			t, ok := v.(time.Time)
+			if !ok {
+				return nil, typeError(col, v)
+			}
+			values = binary.LittleEndian.AppendUint64(values, uint64(t.UnixMicro()))
+		case Date:
+			t, ok := v.(time.Time)
+			if !ok {
+				return nil, typeError(col, v)
+			}
+			y, m, d := t.Date()
+			days := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400
+			values = binary.LittleEndian.AppendUint32(values, uint32(int32(days)))
+		default:
+			var s []byte
+			switch x := v.(type) {
+			case string:
+				s = []byte(x)
+			case []byte:
+				s = x
+			default:
+				s = []byte(fmt.Sprintf("%v", x))
+			}
+			values = binary.LittleEndian.AppendUint32(values, uint32(len(s)))
+			values = append(values, s...)
+		}
+	}
+
+	if col.Type == Boolean {
+		values = make([]byte, (len(bits)+7)/8)
+		for i, b := range bits {
+			if b {
+				values[i/8] |= 1 << (i % 8)
+			}
+		}
+	}
+
+	rle := encodeLevels(levels)
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(rle)))
+	page = append(page, rle...)
+	return append(page, values...), nil
+}
+
+// encodeLevels RLE encodes definition levels with a bit width of 1, using
+// only RLE runs of the RLE/bit-packing hybrid encoding.
+func encodeLevels(levels []byte) []byte {
+	var out []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		out = append(out, levels[i])
+		i = j
+	}
+	return out
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch x := v.(type) {
+	case int64:
+		return x, true
+	case int32:
+		return int64(x), true
+	case int16:
+		return int64(x), true
+	case int8:
+		return int64(x), true
+	case int:
+		return int64(x), true
+	}
+	return 0, false
+}
+
+func typeError(col Column, v interface{}) error {
+	return fmt.Errorf("parquet: column %s: unexpected value of type %T", col.Name, v)
+}
@@ -0,0 +1,375 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// The reader below is written from the Parquet and Thrift compact protocol
+// specs rather than from the writer, so the tests catch a file the writer
+// and a real reader disagree on. It decodes every Thrift type, not just the
+// ones the writer emits.
+
+type compactReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *compactReader) byte() byte {
+	if r.pos >= len(r.buf) {
+		r.err = fmt.Errorf("thrift: read past end at %d", r.pos)
+		return 0
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *compactReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		r.err = fmt.Errorf("thrift: bad varint at %d", r.pos)
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *compactReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) value(typ byte) interface{} {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 3:
+		return int8(r.byte())
+	case 4, 5, 6:
+		return r.zigzag()
+	case 7:
+		if r.pos+8 > len(r.buf) {
+			r.err = fmt.Errorf("thrift: short double at %d", r.pos)
+			return 0.0
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(r.buf[r.pos:]))
+		r.pos += 8
+		return f
+	case 8:
+		n := int(r.uvarint())
+		if r.pos+n > len(r.buf) {
+			r.err = fmt.Errorf("thrift: short binary at %d", r.pos)
+			return ""
+		}
+		s := string(r.buf[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case 9, 10:
+		head := r.byte()
+		size := int(head >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		elems := make([]interface{}, size)
+		for i := range elems {
+			if head&0x0F == 1 || head&0x0F == 2 {
+				elems[i] = r.byte() == 1 // booleans in lists are one byte each
+			} else {
+				elems[i] = r.value(head & 0x0F)
+			}
+		}
+		return elems
+	case 11:
+		size := int(r.uvarint())
+		m := make(map[interface{}]interface{}, size)
+		if size > 0 {
+			kinds := r.byte()
+			for i := 0; i < size; i++ {
+				k := r.value(kinds >> 4)
+				m[k] = r.value(kinds & 0x0F)
+			}
+		}
+		return m
+	case 12:
+		return r.structure()
+	}
+	r.err = fmt.Errorf("thrift: unknown type %d at %d", typ, r.pos)
+	return nil
+}
+
+func (r *compactReader) structure() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for r.err == nil {
+		head := r.byte()
+		if head == 0 {
+			break
+		}
+		id := last + int16(head>>4)
+		if head>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(head & 0x0F)
+		last = id
+	}
+	return fields
+}
+
+// readFile decodes a Parquet file of flat optional columns into its column
+// names and rows.
+func readFile(t *testing.T, file []byte) ([]string, [][]interface{}) {
+	t.Helper()
+	if len(file) < 12 || string(file[:4]) != "PAR1" || string(file[len(file)-4:]) != "PAR1" {
+		t.Fatalf("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := &compactReader{buf: file[len(file)-8-footerLen : len(file)-8]}
+	meta := footer.structure()
+	if footer.err != nil {
+		t.Fatalf("footer: %v", footer.err)
+	}
+
+	schema := meta[2].([]interface{})
+	root := schema[0].(map[int16]interface{})
+	if got := int(root[5].(int64)); got != len(schema)-1 {
+		t.Fatalf("root has %d children, schema lists %d columns", got, len(schema)-1)
+	}
+	var names []string
+	var converted []int64
+	for _, elem := range schema[1:] {
+		e := elem.(map[int16]interface{})
+		names = append(names, e[4].(string))
+		c, ok := e[6].(int64)
+		if !ok {
+			c = -1
+		}
+		converted = append(converted, c)
+	}
+
+	var rows [][]interface{}
+	for _, g := range meta[4].([]interface{}) {
+		group := g.(map[int16]interface{})
+		numRows := int(group[3].(int64))
+		start := len(rows)
+		for i := 0; i < numRows; i++ {
+			rows = append(rows, make([]interface{}, len(names)))
+		}
+		for c, ch := range group[1].([]interface{}) {
+			cm := ch.(map[int16]interface{})[3].(map[int16]interface{})
+			if codec := cm[4].(int64); codec != 0 {
+				t.Fatalf("column %s: codec %d, want uncompressed", names[c], codec)
+			}
+			values := readPage(t, file, int(cm[9].(int64)), cm[1].(int64), numRows)
+			for i, v := range values {
+				rows[start+i][c] = convert(v, converted[c])
+			}
+		}
+	}
+	if got := int(meta[3].(int64)); got != len(rows) {
+		t.Errorf("metadata says %d rows, row groups hold %d", got, len(rows))
+	}
+	return names, rows
+}
+
+// readPage decodes the single v1 data page of a column chunk.
+func readPage(t *testing.T, file []byte, offset int, physical int64, numRows int) []interface{} {
+	t.Helper()
+	r := &compactReader{buf: file, pos: offset}
+	header := r.structure()
+	if r.err != nil {
+		t.Fatalf("page header: %v", r.err)
+	}
+	if typ := header[1].(int64); typ != 0 {
+		t.Fatalf("page type %d, want DATA_PAGE", typ)
+	}
+	page := file[r.pos : r.pos+int(header[3].(int64))]
+	if n := int(header[5].(map[int16]interface{})[1].(int64)); n != numRows {
+		t.Fatalf("page holds %d values, want %d", n, numRows)
+	}
+
+	levelsLen := int(binary.LittleEndian.Uint32(page))
+	levels := decodeHybrid(t, page[4:4+levelsLen], numRows)
+	data := page[4+levelsLen:]
+
+	values := make([]interface{}, numRows)
+	bit := 0
+	for i, defined := range levels {
+		if !defined {
+			continue
+		}
+		switch physical {
+		case physBoolean:
+			values[i] = data[bit/8]&(1<<(bit%8)) != 0
+			bit++
+		case physInt32:
+			values[i] = int32(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case physInt64:
+			values[i] = int64(binary.LittleEndian.Uint64(data))
+			data = data[8:]
+		case physDouble:
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(data))
+			data = data[8:]
+		case physByteArray:
+			n := int(binary.LittleEndian.Uint32(data))
+			values[i] = string(data[4 : 4+n])
+			data = data[4+n:]
+		default:
+			t.Fatalf("unexpected physical type %d", physical)
+		}
+	}
+	return values
+}
+
+// decodeHybrid decodes n definition levels of bit width 1 from the
+// RLE/bit-packing hybrid encoding.
+func decodeHybrid(t *testing.T, buf []byte, n int) []bool {
+	t.Helper()
+	var levels []bool
+	for len(levels) < n {
+		head, k := binary.Uvarint(buf)
+		if k <= 0 {
+			t.Fatalf("levels: bad run header")
+		}
+		buf = buf[k:]
+		if head&1 == 0 {
+			count := int(head >> 1)
+			for i := 0; i < count; i++ {
+				levels = append(levels, buf[0] == 1)
+			}
+			buf = buf[1:]
+		} else {
+			groups := int(head >> 1)
+			for i := 0; i < groups*8; i++ {
+				levels = append(levels, buf[i/8]&(1<<(i%8)) != 0)
+			}
+			buf = buf[groups:]
+		}
+	}
+	return levels[:n]
+}
+
+func convert(v interface{}, converted int64) interface{} {
+	switch {
+	case v == nil:
+		return nil
+	case converted == convDate:
+		return time.Unix(int64(v.(int32))*86400, 0).UTC()
+	case converted == convTimestampMicros:
+		return time.UnixMicro(v.(int64)).UTC()
+	}
+	return v
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	columns := []Column{
+		{Name: "name", Type: String},
+		{Name: "active", Type: Boolean},
+		{Name: "age", Type: Int32},
+		{Name: "id", Type: Int64},
+		{Name: "score", Type: Double},
+		{Name: "seen", Type: Timestamp},
+		{Name: "born", Type: Date},
+	}
+	seen := time.Date(2024, 3, 1, 12, 30, 15, 123456000, time.UTC)
+	born := time.Date(1990, 7, 4, 0, 0, 0, 0, time.UTC)
+	in := [][]interface{}{
+		{"Ana", true, int64(34), int64(1), 9.5, seen, born},
+		{nil, nil, nil, nil, nil, nil, nil},
+		{[]byte("Bo"), false, int32(-2), int(1 << 40), float32(0.25), seen.Add(time.Hour), born.AddDate(0, 0, 1)},
+		{"", true, int8(0), int64(-1), int64(3), nil, nil},
+	}
+	want := [][]interface{}{
+		{"Ana", true, int32(34), int64(1), 9.5, seen, born},
+		{nil, nil, nil, nil, nil, nil, nil},
+		{"Bo", false, int32(-2), int64(1 << 40), 0.25, seen.Add(time.Hour), born.AddDate(0, 0, 1)},
+		{"", true, int32(0), int64(-1), 3.0, nil, nil},
+	}
+
+	var buf bytes.Buffer
+	pw := NewWriter(&buf, columns)
+	for _, row := range in {
+		if err := pw.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names, rows := readFile(t, buf.Bytes())
+	if want := []string{"name", "active", "age", "id", "score", "seen", "born"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("columns = %v, want %v", names, want)
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v\nwant %v", rows, want)
+	}
+}
+
+func TestWriterSplitsRowGroups(t *testing.T) {
+	var buf bytes.Buffer
+	pw := NewWriter(&buf, []Column{{Name: "n", Type: Int64}, {Name: "odd", Type: Boolean}})
+	total := RowGroupSize*2 + 3
+	for i := 0; i < total; i++ {
+		var odd interface{}
+		if i%3 != 0 {
+			odd = i%2 == 1
+		}
+		if err := pw.WriteRow([]interface{}{int64(i), odd}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, rows := readFile(t, buf.Bytes())
+	if len(rows) != total {
+		t.Fatalf("read %d rows, want %d", len(rows), total)
+	}
+	for i, row := range rows {
+		if row[0] != int64(i) {
+			t.Fatalf("row %d: n = %v", i, row[0])
+		}
+		if i%3 == 0 && row[1] != nil || i%3 != 0 && row[1] != (i%2 == 1) {
+			t.Fatalf("row %d: odd = %v", i, row[1])
+		}
+	}
+}
+
+func TestWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriter(&buf, []Column{{Name: "name", Type: String}}).Close(); err != nil {
+		t.Fatal(err)
+	}
+	names, rows := readFile(t, buf.Bytes())
+	if !reflect.DeepEqual(names, []string{"name"}) || len(rows) != 0 {
+		t.Errorf("got columns %v and %d rows, want [name] and none", names, len(rows))
+	}
+}
+
+func TestWriterRejectsBadRows(t *testing.T) {
+	columns := []Column{{Name: "id", Type: Int64}}
+
+	pw := NewWriter(&bytes.Buffer{}, columns)
+	if err := pw.WriteRow([]interface{}{int64(1), "extra"}); err == nil {
+		t.Error("WriteRow accepted a row with too many values")
+	}
+
+	pw = NewWriter(&bytes.Buffer{}, columns)
+	if err := pw.WriteRow([]interface{}{"one"}); err != nil {
+		t.Fatal(err) // rows are only checked when their group is written
+	}
+	if err := pw.Close(); err == nil {
+		t.Error("Close accepted a string in an Int64 column")
+	}
+}
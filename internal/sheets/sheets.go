@@ -0,0 +1,109 @@
+// Package sheets writes query results to Google Sheets.
+package sheets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+)
+
+// Client writes result sets to spreadsheets as a service account.
+type Client struct {
+	svc *sheets.Service
+}
+
+// NewClient creates a client authenticated with the service account key in
+// credentialsFile. Spreadsheets it creates are owned by that account, so
+// sharing an existing spreadsheet with it is usually more convenient.
+func NewClient(ctx context.Context, credentialsFile string) (*Client, error) {
+	svc, err := sheets.NewService(ctx,
+		option.WithAuthCredentialsFile(option.ServiceAccount, credentialsFile),
+		option.WithScopes(sheets.SpreadsheetsScope))
+	if err != nil {
+		return nil, err
+	}
+	return &Client{svc: svc}, nil
+}
+
+// Export writes columns and rows, as returned by a query, to the first sheet
+// of spreadsheetID, replacing what was there. With no spreadsheetID a new
+// spreadsheet called title is created. It returns the spreadsheet's URL.
+func (c *Client) Export(ctx context.Context, spreadsheetID, title string, columns []string, rows []map[string]interface{}) (string, error) {
+	var spreadsheet *sheets.Spreadsheet
+	var err error
+	if spreadsheetID == "" {
+		spreadsheet, err = c.svc.Spreadsheets.Create(&sheets.Spreadsheet{
+			Properties: &sheets.SpreadsheetProperties{Title: title},
+		}).Context(ctx).Do()
+	} else {
+		spreadsheet, err = c.svc.Spreadsheets.Get(spreadsheetID).
+			Fields("spreadsheetId", "spreadsheetUrl", "sheets.properties.title").Context(ctx).Do()
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(spreadsheet.Sheets) == 0 {
+		return "", fmt.Errorf("spreadsheet %s has no sheets", spreadsheet.SpreadsheetId)
+	}
+	sheet := quoteSheetName(spreadsheet.Sheets[0].Properties.Title)
+
+	if spreadsheetID != "" {
+		_, err := c.svc.Spreadsheets.Values.Clear(spreadsheet.SpreadsheetId, sheet, &sheets.ClearValuesRequest{}).Context(ctx).Do()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	values := make([][]interface{}, 0, len(rows)+1)
+	header := make([]interface{}, len(columns))
+	for i, col := range columns {
+		header[i] = col
+	}
+	values = append(values, header)
+	for _, row := range rows {
+		record := make([]interface{}, len(columns))
+		for i, col := range columns {
+			record[i] = cellValue(row[col])
+		}
+		values = append(values, record)
+	}
+
+	_, err = c.svc.Spreadsheets.Values.Update(spreadsheet.SpreadsheetId, sheet+"!A1", &sheets.ValueRange{Values: values}).
+		ValueInputOption("RAW").Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	return spreadsheet.SpreadsheetUrl, nil
+}
+
+// cellValue converts a query value to something a cell can hold. Strings,
+// numbers and booleans are kept so the sheet can sort and sum them; arrays
+// and other composite values are written as JSON.
+func cellValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string, bool, int64, float64:
+		return x
+	case []byte:
+		return string(x)
+	case time.Time:
+		return x.Format("2006-01-02 15:04:05")
+	default:
+		b, err := json.Marshal(x)
+		if err != nil {
+			return fmt.Sprint(x)
+		}
+		return string(b)
+	}
+}
+
+// quoteSheetName quotes a sheet name for use in A1 notation.
+func quoteSheetName(name string) string {
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}